@@ -2,13 +2,14 @@ package api
 
 import (
 	"errors"
-	"math/rand"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	jww "github.com/spf13/jwalterweatherman"
 	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+	"github.com/xx-labs/blockchain-cmix-relay/common/logging"
 	"gitlab.com/elixxir/client/v4/restlike"
 	"gitlab.com/elixxir/crypto/contact"
 )
@@ -20,10 +21,34 @@ import (
 type Api struct {
 	client    *cmix.Client
 	logPrefix string
-	retries   int
+	retry     RetryPolicy
+	hedge     HedgeConfig
+	verify    VerifyConfig
+	sanitize  SanitizeConfig
+	metrics   *Metrics
+	cover     *coverTraffic
+	padding   cmix.Padding
+	contacts  *contactsDir
+	directory *relayDirectory
 	relayers  map[string]*Relay
 	active    map[string]bool
+	connected bool
 	mux       sync.RWMutex
+
+	// Network used for requests to /
+	defaultNetwork string
+
+	// Filters by client filter id
+	filters cmix.FilterRoutes
+
+	// Network conflicts between relay servers already logged
+	conflicts   map[string]struct{}
+	conflictMux sync.Mutex
+
+	// Requests in progress, drained on disconnect
+	requests     cmix.RequestTracker
+	drainTimeout time.Duration
+	stopChan     chan struct{}
 }
 
 // Configuration variables for the Api
@@ -34,8 +59,47 @@ type Config struct {
 	// Number of retries for each request
 	Retries int
 
+	// Retry policy for each request
+	// Unset fields take default values
+	// If Retries is set, it overrides the policy MaxRetries
+	Retry RetryPolicy
+
+	// Hedging of requests to multiple relay servers
+	Hedge HedgeConfig
+
+	// Verification of read requests across multiple relay servers
+	Verify VerifyConfig
+
+	// Removal of identifying data from requests
+	Sanitize SanitizeConfig
+
+	// Padding of request data sent to relay servers
+	Padding cmix.Padding
+
+	// Cover traffic and timing obfuscation
+	Cover CoverConfig
+
 	// Server contact files
 	ServerContacts []ServerInfo
+
+	// Directory watched for server contact files
+	// Relay servers are added and removed as files
+	// are added to and removed from the directory
+	// Not watched if empty
+	ContactsDir string
+
+	// Interval between checks of the contacts directory
+	ContactsDirInterval time.Duration
+
+	// Network used for requests to /, given as a
+	// network URI, alias or chain ID path (/chain/<id>)
+	// Requests to / fail if empty
+	DefaultNetwork string
+
+	// Signed relay directory
+	// Relay servers are added and removed
+	// as the directory is refreshed
+	Directory DirectoryConfig
 }
 
 type ServerInfo struct {
@@ -55,6 +119,13 @@ func NewApi(c Config) *Api {
 	// Create cMix client
 	client := cmix.NewClient(c.Cmix)
 
+	// Build retry policy
+	retry := c.Retry
+	if c.Retries > 0 {
+		retry.MaxRetries = c.Retries
+	}
+	retry = retry.withDefaults()
+
 	// Create relay servers
 	relayers := make(map[string]*Relay, len(c.ServerContacts))
 	active := make(map[string]bool, len(c.ServerContacts))
@@ -64,17 +135,34 @@ func NewApi(c Config) *Api {
 		if contactInfo.ContactFile != "" {
 			contact = cmix.LoadContactFile(contactInfo.ContactFile)
 		}
-		relayers[contactInfo.Name] = NewRelay(contactInfo.Name, client, contact, c.Cmix.LogPrefix, c.Retries)
+		relayers[contactInfo.Name] = NewRelay(contactInfo.Name, client, contact, c.Cmix.LogPrefix, retry, c.Padding)
 		active[contactInfo.Name] = false
 	}
 
-	return &Api{
+	a := &Api{
 		client:    client,
 		logPrefix: c.Cmix.LogPrefix,
-		retries:   c.Retries,
+		retry:     retry,
+		hedge:     c.Hedge,
+		verify:    c.Verify,
+		sanitize:  c.Sanitize,
+		metrics:   &Metrics{},
+		padding:   c.Padding,
 		relayers:  relayers,
 		active:    active,
+
+		defaultNetwork: c.DefaultNetwork,
+		drainTimeout:   c.Cmix.DrainTimeout,
+		stopChan:       make(chan struct{}),
+	}
+	a.cover = newCoverTraffic(a, c.Cover)
+	if c.ContactsDir != "" {
+		a.contacts = newContactsDir(a, c.ContactsDir, c.ContactsDirInterval)
 	}
+	if c.Directory.Source != "" {
+		a.directory = newRelayDirectory(a, c.Directory)
+	}
+	return a
 }
 
 // ---------------------------- //
@@ -89,35 +177,81 @@ func (a *Api) Connect() {
 	a.client.Start()
 
 	// Start relayers
+	a.mux.Lock()
+	a.connected = true
 	for _, relayer := range a.relayers {
 		relayer.Start(a.updateRelayers)
 	}
+	a.mux.Unlock()
+
+	// Add relayers from the contacts directory
+	if a.contacts != nil {
+		a.contacts.Start()
+	}
+
+	// Add relayers from the relay directory
+	if a.directory != nil {
+		a.directory.Start()
+	}
 
 	// Wait until at least one relayer is active
-	for {
-		relayers := a.activeRelayers()
-		if len(relayers) > 0 {
-			return
-		}
+	if len(a.Relays()) == 0 {
+		jww.WARN.Printf("[%s] No relay servers configured", a.logPrefix)
+	}
+	for len(a.Relays()) > 0 && len(a.activeRelayers()) == 0 {
 		time.Sleep(1 * time.Second)
 	}
+
+	// Start cover traffic
+	a.cover.Start()
 }
 
 // ---------------------------- //
 // Disconnect the API
-// Stops cMix client
+// New requests are rejected, and requests in progress are
+// given the drain timeout to finish, including retries
+// Then stops cMix client
 // Clears supported networks
 func (a *Api) Disconnect() {
-	// Mark all relayers as not active to prevent new requests
+	// Stop cover traffic
+	a.cover.Stop()
+
+	// Stop watching the contacts directory
+	if a.contacts != nil {
+		a.contacts.Stop()
+	}
+
+	// Stop refreshing the relay directory
+	if a.directory != nil {
+		a.directory.Stop()
+	}
+
+	// Drain requests
+	jww.INFO.Printf("[%s] Draining requests in progress", a.logPrefix)
+	if dropped := a.requests.Drain(a.drainTimeout); dropped > 0 {
+		jww.WARN.Printf("[%s] Dropped %d requests in progress after %v", a.logPrefix, dropped, a.drainTimeout)
+	} else {
+		jww.INFO.Printf("[%s] Drained all requests in progress", a.logPrefix)
+	}
+
+	// Stop retrying dropped requests
+	close(a.stopChan)
+
+	// Mark all relayers as not active
 	a.mux.Lock()
+	a.connected = false
 	for name := range a.active {
 		a.active[name] = false
 	}
+	relayers := make([]*Relay, 0, len(a.relayers))
+	for _, relayer := range a.relayers {
+		relayers = append(relayers, relayer)
+	}
 	a.mux.Unlock()
 
 	// Stop relayers
 	wg := sync.WaitGroup{}
-	for _, relayer := range a.relayers {
+	for _, relayer := range relayers {
 		wg.Add(1)
 		go func(r *Relay) {
 			r.Stop()
@@ -130,12 +264,18 @@ func (a *Api) Disconnect() {
 
 	// Wait for relayers to stop
 	wg.Wait()
+
+	// Log metrics
+	for _, name := range a.metrics.Names() {
+		jww.INFO.Printf("[%s] Metric %s: %d", a.logPrefix, name, a.metrics.Get(name))
+	}
 }
 
 // ---------------------------- //
 // Return list of supported networks
 // NOTE: this list is loaded from each relay server
-// on Api.Connect()
+// on Api.Connect() and when relay servers are added,
+// and refreshed periodically
 func (a *Api) Networks() []string {
 	a.mux.RLock()
 	defer a.mux.RUnlock()
@@ -153,23 +293,93 @@ func (a *Api) Networks() []string {
 	return networks
 }
 
+// ---------------------------- //
+// Return the client side metrics
+func (a *Api) Metrics() *Metrics {
+	return a.metrics
+}
+
 // ---------------------------- //
 // Do a Request over cMix to the given network
 // with the given data
+// The network can be given as a URI, chain ID path or alias
 // Returns response data, code and possible error
+// Identifying data is removed from JSON-RPC requests if configured
+// Filter requests are sent to the relay server with the filter
 func (a *Api) Request(network string, data []byte) ([]byte, int, error) {
-	return a.doRequest(restlike.Post, network, data)
+	network = a.ResolveNetwork(network)
+	if req := cmix.ParseFilterRequest(data); req != nil {
+		return a.filterRequest(network, req, data)
+	}
+	return a.sanitizedRequest(network, data, "")
+}
+
+// do a request, removing identifying data if configured
+// If relay is set, the request is only sent to that relay server,
+// otherwise the verification quorum of the request applies
+func (a *Api) sanitizedRequest(network string, data []byte, relay string) ([]byte, int, error) {
+	options := func(data []byte) requestOptions {
+		if relay != "" {
+			return requestOptions{relay: relay}
+		}
+		return requestOptions{quorum: a.verify.quorumFor(data)}
+	}
+	if !a.sanitize.Enabled() {
+		return a.doRequest(restlike.Post, network, data, nil, options(data))
+	}
+	sanitized := a.sanitize.sanitize(data)
+	if sanitized == nil {
+		return a.doRequest(restlike.Post, network, data, nil, options(data))
+	}
+	if len(sanitized.changes) > 0 {
+		jww.DEBUG.Printf("[%s] Sanitized request: %s", a.logPrefix, sanitized.report())
+	}
+	// All requests were blocked, so nothing is sent
+	if sanitized.data == nil {
+		return sanitized.restore(nil), 200, nil
+	}
+	resp, code, err := a.doRequest(restlike.Post, network, sanitized.data, nil, options(sanitized.data))
+	if err != nil {
+		return resp, code, err
+	}
+	return sanitized.restore(resp), code, nil
 }
 
 // ---------------------------- //
 // Internal functions
 // ---------------------------- //
 
+// Options of a request over cMix
+type requestOptions struct {
+	// Number of relay servers that must agree on the response
+	// Verification is disabled if this is less than 2
+	quorum int
+
+	// Check if the responses of two relay servers agree
+	// If nil, responses must be equal, apart from their ids
+	agree func(a, b []byte) bool
+
+	// Name of the relay server the request is pinned to,
+	// such as the one with a filter
+	// If set, the request is only sent to that relay server,
+	// without verification, hedging or broadcast
+	relay string
+}
+
+// do a request with the given options
+func (a *Api) requestWith(network string, data []byte, opts requestOptions) ([]byte, int, error) {
+	return a.doRequest(restlike.Post, network, data, nil, opts)
+}
+
 // callback to update active relayers
-func (a *Api) updateRelayers(name string, active bool) {
+// Ignored for relayers that were removed
+func (a *Api) updateRelayers(r *Relay, active bool) {
 	a.mux.Lock()
 	defer a.mux.Unlock()
-	a.active[name] = active
+	if a.relayers[r.name] != r {
+		return
+	}
+	a.active[r.name] = active
 }
 
 func (a *Api) activeRelayers() []*Relay {
@@ -185,14 +395,31 @@ func (a *Api) activeRelayers() []*Relay {
 }
 
 // do a request over cMix
+// Headers are sent with the request, except to the custom
+// network, whose headers are the endpoint URL
+// If the quorum of the options is greater than 1, the response
+// must agree between that number of relayers
 func (a *Api) doRequest(
 	method restlike.Method,
 	uri string,
 	data []byte,
+	headers []byte,
+	opts requestOptions,
 ) (resp []byte, code int, err error) {
+	// Reject new requests while disconnecting
+	if !a.requests.Begin() {
+		return nil, 503, fmt.Errorf("client is %w", cmix.ErrShuttingDown)
+	}
+	defer a.requests.End()
+
+	// Request id correlates the log lines of this request
+	prefix := logging.WithRequestId(a.logPrefix, logging.NewRequestId())
+
+	// Delay request by a random amount, if enabled
+	a.cover.jitter()
+
 	// Parse URI
 	endpoint := parseCustomUri(uri)
-	var headers []byte = nil
 
 	// If custom URI
 	if endpoint != "" {
@@ -206,7 +433,7 @@ func (a *Api) doRequest(
 	relayers := a.activeRelayers()
 
 	if len(relayers) == 0 {
-		jww.ERROR.Printf("[%s] No active relayers!", a.logPrefix)
+		jww.ERROR.Printf("[%s] No active relayers!", prefix)
 		return nil, 500, errors.New("relayers not active")
 	}
 
@@ -218,10 +445,25 @@ func (a *Api) doRequest(
 		}
 	}
 	if len(useRelayers) == 0 {
-		jww.ERROR.Printf("[%s] Network %v is not supported", a.logPrefix, uri)
+		jww.ERROR.Printf("[%s] Network %v is not supported", prefix, cmix.RedactURL(uri))
 		return nil, 400, errors.New("unsupported network")
 	}
 
+	// Use only the pinned relayer
+	if opts.relay != "" {
+		pinned := make([]*Relay, 0, 1)
+		for _, r := range useRelayers {
+			if r.name == opts.relay {
+				pinned = append(pinned, r)
+			}
+		}
+		if len(pinned) == 0 {
+			jww.ERROR.Printf("[%s] Relay server %s is not available", prefix, opts.relay)
+			return nil, 503, fmt.Errorf("relay server %s not available", opts.relay)
+		}
+		useRelayers = pinned
+	}
+
 	// Build request
 	request := cmix.Request{
 		Method:  method,
@@ -231,25 +473,39 @@ func (a *Api) doRequest(
 	}
 
 	// Do request over cMix
-	// Repeat for number of retries choosing a different relay server if possible
-	tries := 0
+	// Prefer healthier relay servers and retry with backoff,
+	// choosing a different relay server on each attempt if possible
+	// If verifying, send to a quorum of relay servers on each attempt
+	// If hedging, send to multiple relay servers on each attempt
+	// If broadcasting, send to all relay servers on each attempt
+	// If pinned, send to the pinned relay server only
 	if len(useRelayers) > 1 {
-		shuffle(useRelayers)
-	}
-	err = errors.New("dummy")
-	for err != nil {
-		// Choose a different relay server
-		idx := tries % len(useRelayers)
-		resp, code, err = useRelayers[idx].Request(request)
-		tries++
-		if tries >= a.retries {
-			break
-		}
+		sortByScore(useRelayers)
 	}
+	hedge := a.hedge.useFor(data) && opts.relay == ""
+	broadcast := a.hedge.broadcastFor(data) && opts.relay == ""
+	err = a.retry.Do(a.stopChan, func(attempt int) error {
+		jww.DEBUG.Printf("[%s] Sending request to %v (attempt %d)", prefix, cmix.RedactURL(uri), attempt+1)
+		if broadcast {
+			resp, code, err = a.broadcastRequest(useRelayers, request)
+		} else if opts.quorum > 1 && opts.relay == "" {
+			resp, code, err = a.verifiedRequest(rotate(useRelayers, attempt), request, opts)
+		} else if hedge {
+			resp, code, err = a.hedgedRequest(rotate(useRelayers, attempt*a.hedge.Relays), request)
+		} else {
+			r := useRelayers[attempt%len(useRelayers)]
+			resp, code, err = r.Request(request)
+		}
+		return err
+	})
 
-	// Bail if can't do request in specified number of retries
+	// Bail if can't do request
 	if err != nil {
-		jww.ERROR.Printf("[%s] Failed to send request after %v retries, bailing", a.logPrefix, a.retries)
+		if !isRetryable(err) {
+			jww.ERROR.Printf("[%s] Request failed with non retryable error: %v", prefix, err)
+			return nil, code, err
+		}
+		jww.ERROR.Printf("[%s] Failed to send request after %v retries, bailing: %v", prefix, a.retry.MaxRetries, err)
 		return nil, 500, errors.New("request exhausted number of retries")
 	}
 
@@ -266,21 +522,3 @@ func parseCustomUri(uri string) string {
 	}
 	return endpoint
 }
-
-// Shuffle slice of relayers
-func shuffle(relayers []*Relay) {
-	// Get the length of the slice
-	n := len(relayers)
-
-	// Initialize a random number generator with a seed based on the current time
-	rand.Seed(time.Now().UnixNano())
-
-	// Loop through the slice from the end to the beginning
-	for i := n - 1; i >= 1; i-- {
-		// Generate a random index j between 0 and i
-		j := rand.Intn(i + 1)
-
-		// Swap the elements at index i and j
-		relayers[i], relayers[j] = relayers[j], relayers[i]
-	}
-}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// ---------------------------- //
+// Authentication of the HTTP proxy
+//
+// If a bearer token or basic auth user is configured,
+// requests must carry the credentials
+// Origins of requests from websites are checked before,
+// by the browser origin policy
+
+// Check the credentials of a request
+// Writes an error response and returns false if not allowed
+func (hp *HttpProxy) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if hp.authenticated(r) {
+		return true
+	}
+	jww.WARN.Printf("[%s] Request without valid credentials", hp.logPrefix)
+	if hp.config.User != "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="cMix relay client"`)
+	}
+	// 401 Unauthorized
+	w.WriteHeader(http.StatusUnauthorized)
+	return false
+}
+
+// Check the credentials of a request
+// Always true if no credentials are configured
+func (hp *HttpProxy) authenticated(r *http.Request) bool {
+	if hp.config.Token == "" && hp.config.User == "" {
+		return true
+	}
+	if hp.config.Token != "" {
+		auth := r.Header.Get("Authorization")
+		if strings.HasPrefix(auth, "Bearer ") && secretEqual(strings.TrimPrefix(auth, "Bearer "), hp.config.Token) {
+			return true
+		}
+	}
+	if hp.config.User != "" {
+		user, password, ok := r.BasicAuth()
+		// Both are compared, so that timing doesn't reveal which is wrong
+		userOk := secretEqual(user, hp.config.User)
+		passwordOk := secretEqual(password, hp.config.Password)
+		if ok && userOk && passwordOk {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare secrets in constant time
+func secretEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
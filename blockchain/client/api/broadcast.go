@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// Metric names
+const (
+	MetricBroadcastSuccess = "broadcast_relay_success"
+	MetricBroadcastFailed  = "broadcast_relay_failed"
+)
+
+// ---------------------------- //
+// Send the request to all relay servers at once
+// Returns the first successful response without a JSON-RPC error
+// If all responses have errors, the first response is returned
+// Outcomes of the remaining requests are logged as they arrive
+func (a *Api) broadcastRequest(relayers []*Relay, request cmix.Request) ([]byte, int, error) {
+	jww.INFO.Printf("[%s] Broadcasting write request to %d relay servers", a.logPrefix, len(relayers))
+
+	// Buffered so that requests finishing after returning don't block
+	results := make(chan relayResult, len(relayers))
+	for _, r := range relayers {
+		go func(r *Relay) {
+			resp, code, err := r.Request(request)
+			if err != nil {
+				jww.WARN.Printf("[%s] Broadcast to relay server %s failed: %v", a.logPrefix, r.name, err)
+				a.metrics.Inc(MetricBroadcastFailed)
+			} else if cmix.HasJsonRpcError(resp) {
+				jww.WARN.Printf("[%s] Broadcast to relay server %s returned an error response", a.logPrefix, r.name)
+				a.metrics.Inc(MetricBroadcastFailed)
+			} else {
+				jww.INFO.Printf("[%s] Broadcast to relay server %s succeeded", a.logPrefix, r.name)
+				a.metrics.Inc(MetricBroadcastSuccess)
+			}
+			results <- relayResult{r, resp, code, err}
+		}(r)
+	}
+
+	var first *relayResult
+	last := relayResult{code: 500, err: errors.New("no relay server responded")}
+	for range relayers {
+		res := <-results
+		if res.err != nil {
+			last = res
+			continue
+		}
+		if !cmix.HasJsonRpcError(res.resp) {
+			return res.resp, res.code, nil
+		}
+		if first == nil {
+			first = &res
+		}
+	}
+	if first != nil {
+		return first.resp, first.code, nil
+	}
+	return nil, last.code, last.err
+}
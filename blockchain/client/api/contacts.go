@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// Extension of contact files in the contacts directory
+const contactFileExt = ".xxc"
+
+// Default interval between checks of the contacts directory
+const defaultContactsDirInterval = 10 * time.Second
+
+// ---------------------------- //
+// contactsDir watches a directory of relay server contact files
+// Each contact file adds a relay server named after the file,
+// without extension
+// Relay servers are removed when their file is removed,
+// and replaced when their file changes
+// The directory is polled, so that it works on all platforms
+// and with mounted volumes
+type contactsDir struct {
+	api      *Api
+	path     string
+	interval time.Duration
+
+	// Contact files seen in the directory, by relay server name
+	files map[string]contactFile
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newContactsDir(api *Api, path string, interval time.Duration) *contactsDir {
+	if interval <= 0 {
+		interval = defaultContactsDirInterval
+	}
+	return &contactsDir{
+		api:      api,
+		path:     path,
+		interval: interval,
+		files:    make(map[string]contactFile),
+	}
+}
+
+// contactFile is a contact file seen in the directory
+type contactFile struct {
+	// Modification time and size of the file
+	info os.FileInfo
+	// Whether its relay server was added
+	added bool
+}
+
+// Add the relay servers in the directory
+// and start watching it for changes
+func (d *contactsDir) Start() {
+	jww.INFO.Printf("[%s] Watching contacts directory %s", d.api.logPrefix, d.path)
+	d.scan()
+	d.stopChan = make(chan struct{})
+	d.wg.Add(1)
+	go d.run()
+}
+
+// Stop watching the directory
+// Relay servers added from the directory are kept
+func (d *contactsDir) Stop() {
+	if d.stopChan == nil {
+		return
+	}
+	close(d.stopChan)
+	d.wg.Wait()
+	d.stopChan = nil
+}
+
+func (d *contactsDir) run() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stopChan:
+			return
+		case <-ticker.C:
+			d.scan()
+		}
+	}
+}
+
+// Add, replace and remove relay servers
+// according to the contact files in the directory
+func (d *contactsDir) scan() {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		jww.ERROR.Printf("[%s] Couldn't read contacts directory %s: %v", d.api.logPrefix, d.path, err)
+		return
+	}
+
+	found := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != contactFileExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), contactFileExt)
+		found[name] = struct{}{}
+
+		// Skip unchanged files, including those that failed to load
+		prev, ok := d.files[name]
+		if ok && prev.info.ModTime().Equal(info.ModTime()) && prev.info.Size() == info.Size() {
+			continue
+		}
+		if ok {
+			jww.INFO.Printf("[%s] Contact file of relay server %s changed", d.api.logPrefix, name)
+			d.remove(name)
+		}
+		err = d.api.AddRelay(ServerInfo{
+			ContactFile: filepath.Join(d.path, entry.Name()),
+			Name:        name,
+		})
+		if err != nil {
+			jww.ERROR.Printf("[%s] Couldn't add relay server %s from contacts directory: %v", d.api.logPrefix, name, err)
+		}
+		d.files[name] = contactFile{info, err == nil}
+	}
+
+	// Remove relay servers whose file was removed
+	for name := range d.files {
+		if _, ok := found[name]; !ok {
+			d.remove(name)
+		}
+	}
+}
+
+// Remove a relay server added from the directory
+func (d *contactsDir) remove(name string) {
+	file := d.files[name]
+	delete(d.files, name)
+	if !file.added {
+		return
+	}
+	if err := d.api.RemoveRelay(name); err != nil && !errors.Is(err, ErrRelayNotFound) {
+		jww.ERROR.Printf("[%s] Couldn't remove relay server %s: %v", d.api.logPrefix, name, err)
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"crypto/rand"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+	"gitlab.com/elixxir/client/v4/restlike"
+)
+
+// ---------------------------- //
+// CoverConfig controls cover traffic and
+// timing obfuscation of requests
+type CoverConfig struct {
+	// Average number of dummy requests per minute,
+	// sent at random (Poisson distributed) times
+	// Cover traffic is disabled if this is zero
+	Rate float64
+
+	// Maximum random delay added to real requests
+	// Jitter is disabled if this is zero
+	MaxJitter time.Duration
+}
+
+// URI of the relay server endpoint that discards requests
+const noopUri = "/noop"
+
+// Size range of dummy request payloads
+const (
+	coverMinSize = 64
+	coverMaxSize = 1024
+)
+
+// Metric names
+const (
+	MetricCoverSent   = "cover_sent"
+	MetricCoverFailed = "cover_failed"
+)
+
+// coverTraffic sends dummy requests to relay servers
+type coverTraffic struct {
+	api      *Api
+	config   CoverConfig
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newCoverTraffic(api *Api, config CoverConfig) *coverTraffic {
+	return &coverTraffic{
+		api:    api,
+		config: config,
+	}
+}
+
+// Start sending dummy requests, if enabled
+func (c *coverTraffic) Start() {
+	if c.config.Rate <= 0 {
+		return
+	}
+	jww.INFO.Printf("[%s] Starting cover traffic at %.2f requests per minute", c.api.logPrefix, c.config.Rate)
+	c.stopChan = make(chan struct{})
+	c.wg.Add(1)
+	go c.run()
+}
+
+// Stop sending dummy requests and wait for the
+// sending goroutine to exit
+func (c *coverTraffic) Stop() {
+	if c.stopChan == nil {
+		return
+	}
+	close(c.stopChan)
+	c.wg.Wait()
+	c.stopChan = nil
+}
+
+func (c *coverTraffic) run() {
+	defer c.wg.Done()
+	for {
+		// Exponential inter-arrival times give a Poisson process
+		delay := time.Duration(mrand.ExpFloat64() / c.config.Rate * float64(time.Minute))
+		timer := time.NewTimer(delay)
+		select {
+		case <-c.stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		// Send in the background, so the timing of dummy requests
+		// doesn't depend on the latency of previous ones
+		go c.send()
+	}
+}
+
+// Send a dummy request with a random payload to a random active relay server
+func (c *coverTraffic) send() {
+	relayers := c.api.activeRelayers()
+	if len(relayers) == 0 {
+		return
+	}
+	r := relayers[mrand.Intn(len(relayers))]
+	data := make([]byte, coverMinSize+mrand.Intn(coverMaxSize-coverMinSize))
+	if _, err := rand.Read(data); err != nil {
+		return
+	}
+	err := r.Noop(data)
+	if err != nil {
+		jww.DEBUG.Printf("[%s] Cover request to relay server %s failed: %v", c.api.logPrefix, r.name, err)
+		c.api.metrics.Inc(MetricCoverFailed)
+		return
+	}
+	c.api.metrics.Inc(MetricCoverSent)
+}
+
+// Wait a random delay up to the maximum jitter
+func (c *coverTraffic) jitter() {
+	if c.config.MaxJitter <= 0 {
+		return
+	}
+	time.Sleep(time.Duration(mrand.Int63n(int64(c.config.MaxJitter))))
+}
+
+// ---------------------------- //
+// Send a dummy request to the relay server noop endpoint
+// The request doesn't affect the relay server score
+func (r *Relay) Noop(data []byte) error {
+	req := cmix.Request{
+		Method: restlike.Post,
+		Uri:    noopUri,
+		Data:   r.padding.Pad(data),
+	}
+	response, err := r.client.Request(r.name, r.contact, req)
+	if err != nil {
+		return err
+	}
+	if response.Error != "" {
+		return &RelayError{Code: 500, Msg: response.Error}
+	}
+	return nil
+}
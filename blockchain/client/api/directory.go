@@ -0,0 +1,241 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/blockchain/client/directory"
+)
+
+// Default interval between refreshes of the relay directory
+const defaultDirectoryRefresh = time.Hour
+
+// Maximum interval between retries of a failed refresh
+const maxDirectoryRetry = time.Minute
+
+// Relay directory configuration
+type DirectoryConfig struct {
+	// File path or HTTP(S) URL of the signed relay directory
+	// Not used if empty
+	Source string
+
+	// Public key of the directory publisher
+	Key ed25519.PublicKey
+
+	// Interval between refreshes of the directory
+	Refresh time.Duration
+
+	// File storing the issue time of the last accepted directory,
+	// so that older directories are still rejected after a restart
+	// Not stored if empty
+	IssuedFile string
+}
+
+// ---------------------------- //
+// relayDirectory loads relay servers from a signed relay directory
+// Relay servers are added and removed as they are
+// added to and removed from the directory,
+// and replaced when their contact changes
+// Relay servers with an unsupported protocol version are skipped
+type relayDirectory struct {
+	api    *Api
+	config DirectoryConfig
+
+	// Issue time of the current directory
+	// Loaded from the issued file on start
+	issued time.Time
+
+	// Whether a directory was loaded since start
+	loaded bool
+
+	// Relay servers seen in the directory, by name
+	relays map[string]directoryRelay
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newRelayDirectory(api *Api, config DirectoryConfig) *relayDirectory {
+	if config.Refresh <= 0 {
+		config.Refresh = defaultDirectoryRefresh
+	}
+	d := &relayDirectory{
+		api:    api,
+		config: config,
+		relays: make(map[string]directoryRelay),
+	}
+	d.issued = d.loadIssued()
+	return d
+}
+
+// directoryRelay is a relay server seen in the directory
+type directoryRelay struct {
+	// Marshalled contact of the relay server
+	contact []byte
+	// Whether the relay server was added
+	added bool
+}
+
+// Add the relay servers in the directory
+// and start refreshing it
+func (d *relayDirectory) Start() {
+	jww.INFO.Printf("[%s] Loading relay directory %s", d.api.logPrefix, d.config.Source)
+	err := d.refresh()
+	d.stopChan = make(chan struct{})
+	d.wg.Add(1)
+	go d.run(err != nil)
+}
+
+// Stop refreshing the directory
+// Relay servers added from the directory are kept
+func (d *relayDirectory) Stop() {
+	if d.stopChan == nil {
+		return
+	}
+	close(d.stopChan)
+	d.wg.Wait()
+	d.stopChan = nil
+}
+
+func (d *relayDirectory) run(failed bool) {
+	defer d.wg.Done()
+	for {
+		// Retry sooner after a failure
+		wait := d.config.Refresh
+		if failed && wait > maxDirectoryRetry {
+			wait = maxDirectoryRetry
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-d.stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+			failed = d.refresh() != nil
+		}
+	}
+}
+
+// Load and verify the directory, then add, replace
+// and remove relay servers according to it
+// The current relay servers are kept if the directory
+// can't be loaded, isn't valid or is older than the current one
+func (d *relayDirectory) refresh() error {
+	dir, err := directory.Load(d.config.Source, d.config.Key)
+	if err != nil {
+		jww.ERROR.Printf("[%s] Couldn't load relay directory %s: %v", d.api.logPrefix, d.config.Source, err)
+		return err
+	}
+	if dir.Issued.Before(d.issued) {
+		jww.WARN.Printf("[%s] Ignoring relay directory issued %v, older than last accepted directory issued %v",
+			d.api.logPrefix, dir.Issued, d.issued)
+		// Retry sooner if no directory was loaded since start
+		if !d.loaded {
+			return errors.New("relay directory older than last accepted directory")
+		}
+		return nil
+	}
+	if dir.Issued.Equal(d.issued) && d.loaded {
+		return nil
+	}
+	d.issued = dir.Issued
+	d.loaded = true
+	d.saveIssued()
+	jww.INFO.Printf("[%s] Loaded relay directory issued %v with %d relay servers", d.api.logPrefix, dir.Issued, len(dir.Relays))
+
+	found := make(map[string]struct{}, len(dir.Relays))
+	for _, r := range dir.Relays {
+		if !r.Supported() {
+			jww.WARN.Printf("[%s] Skipping relay server %s from directory with unsupported protocol version %d",
+				d.api.logPrefix, r.Name, r.ProtocolVersion)
+			continue
+		}
+		found[r.Name] = struct{}{}
+
+		// Skip unchanged relay servers
+		prev, ok := d.relays[r.Name]
+		if ok && bytes.Equal(prev.contact, r.Contact) {
+			continue
+		}
+		if ok {
+			jww.INFO.Printf("[%s] Contact of relay server %s changed in directory", d.api.logPrefix, r.Name)
+			d.remove(r.Name)
+		}
+		// Contacts are checked when verifying the directory
+		contact, _ := r.ParseContact()
+		err = d.api.AddRelay(ServerInfo{
+			Contact: contact,
+			Name:    r.Name,
+		})
+		if err != nil {
+			jww.ERROR.Printf("[%s] Couldn't add relay server %s from directory: %v", d.api.logPrefix, r.Name, err)
+		}
+		d.relays[r.Name] = directoryRelay{r.Contact, err == nil}
+	}
+
+	// Remove relay servers no longer in the directory
+	for name := range d.relays {
+		if _, ok := found[name]; !ok {
+			d.remove(name)
+		}
+	}
+	return nil
+}
+
+// Remove a relay server added from the directory
+func (d *relayDirectory) remove(name string) {
+	r := d.relays[name]
+	delete(d.relays, name)
+	if !r.added {
+		return
+	}
+	if err := d.api.RemoveRelay(name); err != nil && !errors.Is(err, ErrRelayNotFound) {
+		jww.ERROR.Printf("[%s] Couldn't remove relay server %s: %v", d.api.logPrefix, name, err)
+	}
+}
+
+// Read the issue time of the last accepted directory
+// Returns the zero time if it isn't stored
+func (d *relayDirectory) loadIssued() time.Time {
+	if d.config.IssuedFile == "" {
+		return time.Time{}
+	}
+	data, err := os.ReadFile(d.config.IssuedFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}
+	}
+	var issued time.Time
+	if err == nil {
+		issued, err = time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	}
+	if err != nil {
+		jww.WARN.Printf("[%s] Couldn't read issue time of last relay directory from %s: %v",
+			d.api.logPrefix, d.config.IssuedFile, err)
+		return time.Time{}
+	}
+	return issued
+}
+
+// Store the issue time of the current directory
+// The file is replaced atomically, so that it can't be left truncated
+func (d *relayDirectory) saveIssued() {
+	if d.config.IssuedFile == "" {
+		return
+	}
+	tmp := d.config.IssuedFile + ".tmp"
+	data := d.issued.UTC().Format(time.RFC3339Nano) + "\n"
+	err := os.WriteFile(tmp, []byte(data), 0600)
+	if err == nil {
+		err = os.Rename(tmp, d.config.IssuedFile)
+	}
+	if err != nil {
+		jww.WARN.Printf("[%s] Couldn't store issue time of relay directory in %s: %v",
+			d.api.logPrefix, d.config.IssuedFile, err)
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xx-labs/blockchain-cmix-relay/blockchain/client/directory"
+)
+
+// The issue time of the last accepted directory is stored,
+// so that an older directory is rejected after a restart
+func TestRelayDirectoryRollback(t *testing.T) {
+	public, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	source := filepath.Join(dir, "directory.json")
+	config := DirectoryConfig{
+		Source:     source,
+		Key:        public,
+		IssuedFile: filepath.Join(dir, "state.directory"),
+	}
+	publish := func(issued time.Time) {
+		t.Helper()
+		data, err := directory.Sign(&directory.Directory{Version: directory.Version, Issued: issued}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(source, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := &Api{}
+	issued := time.Now().UTC().Truncate(time.Second)
+
+	publish(issued)
+	if err := newRelayDirectory(a, config).refresh(); err != nil {
+		t.Fatalf("refresh() error: %v", err)
+	}
+
+	// Restart with an older directory
+	publish(issued.Add(-time.Hour))
+	d := newRelayDirectory(a, config)
+	if err := d.refresh(); err == nil || d.loaded {
+		t.Errorf("refresh() of older directory after restart = %v, loaded %v, want rejected", err, d.loaded)
+	}
+
+	// Restart with the same directory
+	publish(issued)
+	d = newRelayDirectory(a, config)
+	if err := d.refresh(); err != nil || !d.loaded {
+		t.Errorf("refresh() of same directory after restart = %v, loaded %v, want loaded", err, d.loaded)
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// ---------------------------- //
+// Filter routing to relay servers
+//
+// Requests can go to any relay server, or to several of them when
+// verifying or hedging, so filters are created on the healthiest
+// relay server, and requests using them are pinned to it, without
+// verification or hedging
+// Filters of relay servers that are removed or inactive are lost,
+// and clients get a filter not found error, so they can create them again
+
+// ---------------------------- //
+// Do a filter request, on the healthiest relay server
+// for filter creation, or on the relay server of the filter
+func (a *Api) filterRequest(network string, req *cmix.FilterRequest, data []byte) ([]byte, int, error) {
+	if req.Creates() {
+		return a.createFilter(network, req, data)
+	}
+	return a.useFilter(network, req)
+}
+
+// Create a filter on the healthiest relay server
+func (a *Api) createFilter(network string, req *cmix.FilterRequest, data []byte) ([]byte, int, error) {
+	if a.filters.Full() {
+		jww.WARN.Printf("[%s] Too many filters, refusing %s", a.logPrefix, req.Method)
+		return cmix.FilterError(req.Id, -32000, "too many filters"), 200, nil
+	}
+	relayers := a.networkRelayers(network)
+	// Errors are reported by the request
+	if len(relayers) == 0 {
+		return a.sanitizedRequest(network, data, "")
+	}
+	sortByScore(relayers)
+	relay := relayers[0].name
+	resp, code, err := a.sanitizedRequest(network, data, relay)
+	if err != nil || code != 200 {
+		return resp, code, err
+	}
+	resp, id, err := a.filters.AddResponse(relay, network, resp)
+	if err != nil {
+		return nil, 500, err
+	}
+	if id != "" {
+		jww.DEBUG.Printf("[%s] Created filter %s on relay server %s", a.logPrefix, id, relay)
+	}
+	return resp, code, nil
+}
+
+// Send a request using a filter to the relay server with the filter
+func (a *Api) useFilter(network string, req *cmix.FilterRequest) ([]byte, int, error) {
+	id, ok := req.FilterId()
+	if !ok {
+		return cmix.FilterError(req.Id, -32602, "missing filter id"), 200, nil
+	}
+	filter := a.filters.Get(id, req.Uninstalls())
+	if filter != nil && (filter.Network != network || !a.relayAvailable(filter.Target, network)) {
+		jww.INFO.Printf("[%s] Filter %s is on unavailable relay server %s", a.logPrefix, id, filter.Target)
+		a.filters.Remove(id)
+		filter = nil
+	}
+	if filter == nil {
+		return req.NotFound(), 200, nil
+	}
+	data, err := req.ForTarget(filter)
+	if err != nil {
+		return nil, 500, err
+	}
+	return a.sanitizedRequest(network, data, filter.Target)
+}
+
+// Get the active relay servers supporting a network
+func (a *Api) networkRelayers(network string) []*Relay {
+	// Custom URIs are sent to the custom network
+	if parseCustomUri(network) != "" {
+		network = "/custom"
+	}
+	relayers := make([]*Relay, 0)
+	for _, r := range a.activeRelayers() {
+		if r.SupportsNetwork(network) {
+			relayers = append(relayers, r)
+		}
+	}
+	return relayers
+}
+
+// Check if a relay server is active and supports a network
+func (a *Api) relayAvailable(name, network string) bool {
+	for _, r := range a.networkRelayers(network) {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gitlab.com/elixxir/client/v4/restlike"
+)
+
+// Filters of relay servers that are no longer available aren't
+// sent to other relay servers, which don't have them
+func TestUseFilterUnavailable(t *testing.T) {
+	network := "/ethereum/mainnet"
+	a := &Api{
+		relayers: map[string]*Relay{
+			"relay-1": {name: "relay-1", supportedNetworks: map[string]struct{}{network: {}}},
+			"relay-2": {name: "relay-2", supportedNetworks: map[string]struct{}{network: {}}},
+		},
+		active: map[string]bool{"relay-1": false, "relay-2": true},
+	}
+	id := a.filters.Add("relay-1", network, json.RawMessage(`"0x1"`))
+	other := a.filters.Add("relay-2", network, json.RawMessage(`"0x2"`))
+
+	tests := []struct {
+		name    string
+		data    string
+		code    int
+		result  string
+		network string
+	}{
+		{"inactive relay", `{"jsonrpc":"2.0","id":1,"method":"eth_getFilterChanges","params":["` + id + `"]}`, -32000, "", network},
+		{"forgotten after failure", `{"jsonrpc":"2.0","id":1,"method":"eth_getFilterLogs","params":["` + id + `"]}`, -32000, "", network},
+		{"unknown filter", `{"jsonrpc":"2.0","id":1,"method":"eth_getFilterChanges","params":["0x3"]}`, -32000, "", network},
+		{"uninstall unknown filter", `{"jsonrpc":"2.0","id":1,"method":"eth_uninstallFilter","params":["0x3"]}`, 0, "false", network},
+		{"missing filter id", `{"jsonrpc":"2.0","id":1,"method":"eth_getFilterChanges","params":[]}`, -32602, "", network},
+		{"other network", `{"jsonrpc":"2.0","id":1,"method":"eth_getFilterChanges","params":["` + other + `"]}`, -32000, "", "/ethereum/goerli"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, code, err := a.Request(tt.network, []byte(tt.data))
+			if err != nil || code != 200 {
+				t.Fatalf("Request() = %d, %v", code, err)
+			}
+			var response jsonRpcResponse
+			if err := json.Unmarshal(resp, &response); err != nil {
+				t.Fatalf("invalid response %s: %v", resp, err)
+			}
+			switch {
+			case tt.code != 0 && (response.Error == nil || response.Error.Code != tt.code):
+				t.Errorf("response %s, want error %d", resp, tt.code)
+			case tt.code == 0 && string(response.Result) != tt.result:
+				t.Errorf("response %s, want result %s", resp, tt.result)
+			}
+		})
+	}
+	if a.filters.Get(other, false) != nil {
+		t.Error("filter used on another network wasn't forgotten")
+	}
+}
+
+// Requests pinned to a relay server that isn't available fail,
+// instead of being sent to another relay server
+func TestPinnedRequestUnavailable(t *testing.T) {
+	network := "/ethereum/mainnet"
+	a := &Api{
+		metrics: &Metrics{},
+		relayers: map[string]*Relay{
+			"relay-2": {name: "relay-2", supportedNetworks: map[string]struct{}{network: {}}},
+		},
+		active: map[string]bool{"relay-2": true},
+		cover:  newCoverTraffic(nil, CoverConfig{}),
+	}
+	_, code, err := a.doRequest(restlike.Post, network, []byte(`{}`), nil, requestOptions{relay: "relay-1"})
+	if err == nil || code != 503 {
+		t.Errorf("doRequest() = %d, %v, want 503 error", code, err)
+	}
+}
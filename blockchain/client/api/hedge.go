@@ -0,0 +1,138 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// ---------------------------- //
+// HedgeConfig controls sending requests
+// to multiple relay servers in parallel
+// The first successful response is used
+// and the remaining ones are ignored
+type HedgeConfig struct {
+	// Maximum number of relay servers used for each request
+	// Hedging is disabled if this is less than 2
+	Relays int
+
+	// Delay before sending the request to the next relay server
+	// If zero, the request is sent to all relay servers at once
+	Delay time.Duration
+
+	// Policy for requests containing write methods
+	WritePolicy WritePolicy
+}
+
+// WritePolicy defines how requests that submit
+// transactions are sent to relay servers
+type WritePolicy string
+
+const (
+	// Send write requests to a single relay server at a time
+	WritePolicySingle WritePolicy = "single"
+	// Hedge write requests in the same way as read requests
+	WritePolicyHedge WritePolicy = "hedge"
+	// Send write requests to all relay servers supporting the network
+	WritePolicyBroadcast WritePolicy = "broadcast"
+)
+
+// Check if hedging should be used for the given request data
+func (h HedgeConfig) useFor(data []byte) bool {
+	if h.Relays < 2 {
+		return false
+	}
+	if cmix.IsWriteRequest(data) {
+		return h.WritePolicy == WritePolicyHedge
+	}
+	return true
+}
+
+// Check if the given request data should be broadcast to all relay servers
+func (h HedgeConfig) broadcastFor(data []byte) bool {
+	return h.WritePolicy == WritePolicyBroadcast && cmix.IsWriteRequest(data)
+}
+
+// Result of a request to a single relay server
+type relayResult struct {
+	relay *Relay
+	resp  []byte
+	code  int
+	err   error
+}
+
+// ---------------------------- //
+// Send the request to multiple relay servers, staggered by the hedge delay
+// A failed request immediately triggers the request to the next relay server
+// Returns the first successful response, or the last error if all fail
+func (a *Api) hedgedRequest(relayers []*Relay, request cmix.Request) ([]byte, int, error) {
+	n := a.hedge.Relays
+	if n > len(relayers) {
+		n = len(relayers)
+	}
+
+	// Buffered so that ignored requests don't block
+	results := make(chan relayResult, n)
+	launched := 0
+	launch := func() {
+		r := relayers[launched]
+		launched++
+		go func() {
+			resp, code, err := r.Request(request)
+			results <- relayResult{r, resp, code, err}
+		}()
+	}
+
+	// Timer to send to the next relay server
+	timer := time.NewTimer(a.hedge.Delay)
+	defer timer.Stop()
+	launch()
+	if a.hedge.Delay == 0 {
+		for launched < n {
+			launch()
+		}
+	}
+
+	// Wait for the first successful response
+	received := 0
+	last := relayResult{code: 500, err: errors.New("no relay server responded")}
+	for received < launched {
+		select {
+		case res := <-results:
+			received++
+			if res.err == nil {
+				if launched > received {
+					jww.DEBUG.Printf("[%s] Got response from relay server %s, ignoring %d pending requests",
+						a.logPrefix, res.relay.name, launched-received)
+				}
+				return res.resp, res.code, nil
+			}
+			last = res
+			if !isRetryable(res.err) {
+				return nil, res.code, res.err
+			}
+			// Try the next relay server right away
+			if launched < n {
+				launch()
+			}
+		case <-timer.C:
+			if launched < n {
+				jww.DEBUG.Printf("[%s] Hedging request to relay server %s", a.logPrefix, relayers[launched].name)
+				launch()
+				timer.Reset(a.hedge.Delay)
+			}
+		}
+	}
+	return nil, last.code, last.err
+}
+
+// Return a copy of the slice of relayers
+// rotated to start at the given offset
+func rotate(relayers []*Relay, offset int) []*Relay {
+	rotated := make([]*Relay, 0, len(relayers))
+	offset = offset % len(relayers)
+	rotated = append(rotated, relayers[offset:]...)
+	return append(rotated, relayers[:offset]...)
+}
@@ -2,55 +2,179 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/gorilla/websocket"
 	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+	"github.com/xx-labs/blockchain-cmix-relay/common/cors"
 )
 
+// ---------------------------- //
+// ProxyConfig controls where the HTTP proxy servers
+// listen and who can use them
+type ProxyConfig struct {
+	// Address to listen on, DefaultAddress if empty
+	Address string
+
+	// Certificate to serve HTTPS with, HTTP if nil
+	Certificate *tls.Certificate
+
+	// Bearer token required in the Authorization header, if set
+	Token string
+
+	// User and password required with HTTP basic auth, if set
+	// If both a token and a user are set, either is accepted
+	User     string
+	Password string
+
+	// Browser origin policy, applied before authentication
+	// If nil, origins are not checked and no CORS headers are sent
+	Origins *cors.Policy
+
+	// Interval between polls of WebSocket subscription filters,
+	// DefaultSubscriptionPoll if zero
+	SubscriptionPoll time.Duration
+}
+
+// Default listen address, so that only local programs can use the proxy
+const DefaultAddress = "127.0.0.1"
+
 type HttpProxy struct {
-	api       *Api
+	api       Requester
 	port      int
 	logPrefix string
+	logMode   cmix.LogMode
+	config    ProxyConfig
 	srv       *http.Server
+
+	// Network of all requests, if bound to a single network
+	network string
+
+	// Open WebSocket connections, closed when stopping
+	mux      sync.Mutex
+	sessions map[*wsSession]struct{}
+	stopped  bool
 }
 
-func NewHttpProxy(api *Api, port int, logPrefix string) *HttpProxy {
-	hp := &HttpProxy{api, port, logPrefix, nil}
+// Create a new HTTP proxy server
+// Requests are performed by the given Requester,
+// which can be the Api or a Verifier
+// Request and response data is logged according to the log mode
+func NewHttpProxy(api Requester, port int, logPrefix string, logMode cmix.LogMode, config ProxyConfig) *HttpProxy {
+	if config.Address == "" {
+		config.Address = DefaultAddress
+	}
+	if config.SubscriptionPoll <= 0 {
+		config.SubscriptionPoll = DefaultSubscriptionPoll
+	}
+	hp := &HttpProxy{
+		api:       api,
+		port:      port,
+		logPrefix: logPrefix,
+		logMode:   logMode,
+		config:    config,
+		sessions:  make(map[*wsSession]struct{}),
+	}
+	var handler http.Handler = hp
+	if config.Origins != nil {
+		handler = config.Origins.Handler(hp)
+	}
 	hp.srv = &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: hp,
+		Addr:    net.JoinHostPort(config.Address, strconv.Itoa(port)),
+		Handler: handler,
+	}
+	if config.Certificate != nil {
+		hp.srv.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{*config.Certificate},
+			MinVersion:   tls.VersionTLS12,
+		}
 	}
 	return hp
 }
 
+// Create a new HTTP proxy server bound to a single network,
+// for tools that don't allow a path in the RPC URL
+// All requests are sent to the network, whatever their path
+// The network can be given as a URI, alias or chain ID path
+func NewNetworkProxy(api Requester, port int, network string, logPrefix string, logMode cmix.LogMode, config ProxyConfig) *HttpProxy {
+	hp := NewHttpProxy(api, port, logPrefix, logMode, config)
+	hp.network = network
+	return hp
+}
+
+// URL of the HTTP proxy server, such as http://localhost:9296
+func (hp *HttpProxy) Url() string {
+	scheme := "http"
+	if hp.config.Certificate != nil {
+		scheme = "https"
+	}
+	host := hp.config.Address
+	if ip := net.ParseIP(host); ip != nil && (ip.IsLoopback() || ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(hp.port)))
+}
+
 // Start the HTTP proxy server
 // This function blocks on listening for connections
 // Panics on error different than server closed
 func (hp *HttpProxy) Start() {
-	jww.INFO.Printf("[%s] Starting HTTP server on port: %v", hp.logPrefix, hp.port)
-	if err := hp.srv.ListenAndServe(); err != http.ErrServerClosed {
-		jww.FATAL.Panicf("[%s] Error starting HTTP server", hp.logPrefix)
+	if hp.network != "" {
+		jww.INFO.Printf("[%s] Starting HTTP server for %s on: %v", hp.logPrefix, hp.network, hp.srv.Addr)
+	} else {
+		jww.INFO.Printf("[%s] Starting HTTP server on: %v", hp.logPrefix, hp.srv.Addr)
+	}
+	var err error
+	if hp.srv.TLSConfig != nil {
+		err = hp.srv.ListenAndServeTLS("", "")
+	} else {
+		err = hp.srv.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		jww.FATAL.Panicf("[%s] Error starting HTTP server: %v", hp.logPrefix, err)
 	}
 }
 
 // Stop the Http server
 func (hp *HttpProxy) Stop() {
-	jww.INFO.Printf("[%s] Stopping HTTP server on port: %v", hp.logPrefix, hp.port)
+	jww.INFO.Printf("[%s] Stopping HTTP server on: %v", hp.logPrefix, hp.srv.Addr)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer func() {
 		cancel()
 	}()
+	// Wait for requests in progress, then close remaining connections
 	if err := hp.srv.Shutdown(ctx); err != nil {
-		jww.FATAL.Panicf("[%s] Error stopping HTTP server: %v", hp.logPrefix, err)
+		jww.ERROR.Printf("[%s] Error stopping HTTP server, closing connections: %v", hp.logPrefix, err)
+		hp.srv.Close()
 	}
+	// WebSocket connections are not closed by the server
+	hp.mux.Lock()
+	hp.stopped = true
+	for s := range hp.sessions {
+		s.close()
+	}
+	hp.mux.Unlock()
 	jww.INFO.Printf("[%s] HTTP stopped", hp.logPrefix)
 }
 
 // Handle requests
 func (hp *HttpProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !hp.authorize(w, r) {
+		return
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		hp.serveWebSocket(w, r)
+		return
+	}
 	if r.Body != nil {
 		data, err := io.ReadAll(r.Body)
 		if err != nil {
@@ -60,23 +184,25 @@ func (hp *HttpProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		defer r.Body.Close()
+		// Requests to REST networks can have any path
+		// within the network, and an empty body
+		if network, path, ok := hp.api.RestNetwork(hp.networkPath(r.URL.Path)); ok {
+			if r.URL.RawQuery != "" {
+				path += "?" + r.URL.RawQuery
+			}
+			hp.logMode.Log(hp.logPrefix, fmt.Sprintf("Got HTTP %s request to REST network", r.Method), data)
+			resp, code, err := hp.api.RestRequest(network, r.Method, path, data)
+			hp.respond(w, resp, code, err)
+			return
+		}
 		if len(data) > 0 {
-			jww.INFO.Printf("[%s] Got HTTP request: %v", hp.logPrefix, string(data))
-			resp, code, err := hp.api.Request(r.RequestURI, data)
-			if err != nil {
-				jww.ERROR.Printf("[%s] Request returned an error: %v", hp.logPrefix, err)
-				// 500 Internal Server Error
-				w.WriteHeader(http.StatusInternalServerError)
-			} else {
-				// Code from server
-				// Can be 200 OK, 400 Bad Request or 500 Internal Server Error
-				w.WriteHeader(code)
-				if _, err := w.Write(resp); err != nil {
-					jww.ERROR.Printf("[%s] Error writing to HTTP connection: %v", hp.logPrefix, err)
-				} else {
-					jww.INFO.Printf("[%s] Response: %v", hp.logPrefix, string(resp))
-				}
+			hp.logMode.Log(hp.logPrefix, "Got HTTP request", data)
+			network := r.RequestURI
+			if hp.network != "" {
+				network = hp.network
 			}
+			resp, code, err := hp.api.Request(network, data)
+			hp.respond(w, resp, code, err)
 		} else {
 			jww.WARN.Printf("[%s] Empty body request", hp.logPrefix)
 			// 400 Bad Request
@@ -84,3 +210,48 @@ func (hp *HttpProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// Write the response of a request
+func (hp *HttpProxy) respond(w http.ResponseWriter, resp []byte, code int, err error) {
+	if err != nil {
+		jww.ERROR.Printf("[%s] Request returned an error: %v", hp.logPrefix, err)
+		// 500 Internal Server Error
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// Code from server
+	// Can be 200 OK, 400 Bad Request or 500 Internal Server Error
+	w.WriteHeader(code)
+	if _, err := w.Write(resp); err != nil {
+		jww.ERROR.Printf("[%s] Error writing to HTTP connection: %v", hp.logPrefix, err)
+	} else {
+		hp.logMode.Log(hp.logPrefix, "Response", resp)
+	}
+}
+
+// Path of a request within the network the proxy is bound to
+// Unchanged if the proxy isn't bound to a network
+func (hp *HttpProxy) networkPath(path string) string {
+	if hp.network == "" {
+		return path
+	}
+	return strings.TrimSuffix(hp.network, "/") + path
+}
+
+// Track an open WebSocket connection
+// Returns false if the server is stopped
+func (hp *HttpProxy) addSession(s *wsSession) bool {
+	hp.mux.Lock()
+	defer hp.mux.Unlock()
+	if hp.stopped {
+		return false
+	}
+	hp.sessions[s] = struct{}{}
+	return true
+}
+
+func (hp *HttpProxy) removeSession(s *wsSession) {
+	hp.mux.Lock()
+	defer hp.mux.Unlock()
+	delete(hp.sessions, s)
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"encoding/json"
+)
+
+// ---------------------------- //
+// Perform a JSON-RPC call to the given network
+// with the given requester, and unmarshal its result
+func callJsonRpc(r Requester, network, method string, result interface{}, params ...interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	resp, _, err := r.Request(network, data)
+	if err != nil {
+		return err
+	}
+	return parseResult(resp, result)
+}
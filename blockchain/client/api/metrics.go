@@ -0,0 +1,99 @@
+package api
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ---------------------------- //
+// Metrics holds counters of client side events
+// Counters are created on first use and
+// identified by name
+type Metrics struct {
+	counters sync.Map
+}
+
+// Metric names
+const (
+	MetricVerifyAgreed       = "verify_agreed"
+	MetricVerifyDisagreement = "verify_disagreement"
+	MetricVerifyFailed       = "verify_failed"
+)
+
+// Description of each metric, exported as help text
+// Known metrics are exported from start, with a zero value
+var metricHelp = map[string]string{
+	MetricVerifyAgreed:       "Total number of verified responses agreed by a quorum of relay servers",
+	MetricVerifyDisagreement: "Total number of verified responses where relay servers disagreed",
+	MetricVerifyFailed:       "Total number of responses that couldn't be verified by enough relay servers",
+	MetricProofVerified:      "Total number of state reads verified with proofs",
+	MetricProofRejected:      "Total number of state reads rejected since they couldn't be verified with proofs",
+	MetricCoverSent:          "Total number of cover traffic requests sent",
+	MetricCoverFailed:        "Total number of cover traffic requests that failed",
+	MetricBroadcastSuccess:   "Total number of successful write broadcasts to a relay server",
+	MetricBroadcastFailed:    "Total number of failed write broadcasts to a relay server",
+}
+
+// Prefix of the exported metric names
+const metricPrefix = "client_"
+
+// Increment the named counter
+func (m *Metrics) Inc(name string) {
+	counter, _ := m.counters.LoadOrStore(name, new(atomic.Uint64))
+	counter.(*atomic.Uint64).Add(1)
+}
+
+// Return the current value of the named counter
+func (m *Metrics) Get(name string) uint64 {
+	counter, ok := m.counters.Load(name)
+	if !ok {
+		return 0
+	}
+	return counter.(*atomic.Uint64).Load()
+}
+
+// Return a copy of all counters
+func (m *Metrics) Snapshot() map[string]uint64 {
+	snapshot := make(map[string]uint64)
+	m.counters.Range(func(key, value any) bool {
+		snapshot[key.(string)] = value.(*atomic.Uint64).Load()
+		return true
+	})
+	return snapshot
+}
+
+// Return the names of all counters, sorted
+func (m *Metrics) Names() []string {
+	names := make([]string, 0)
+	m.counters.Range(func(key, _ any) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+// ---------------------------- //
+// Metrics is a Prometheus collector, exporting each counter
+// Since counters are created on first use, the collector is unchecked
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	snapshot := m.Snapshot()
+	for name := range metricHelp {
+		if _, ok := snapshot[name]; !ok {
+			snapshot[name] = 0
+		}
+	}
+	for name, value := range snapshot {
+		help, ok := metricHelp[name]
+		if !ok {
+			help = "Total number of " + name + " events"
+		}
+		desc := prometheus.NewDesc(metricPrefix+name+"_total", help, nil, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value))
+	}
+}
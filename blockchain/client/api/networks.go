@@ -0,0 +1,222 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// Prefix of request paths routed by chain ID
+const chainPrefix = "/chain/"
+
+// ---------------------------- //
+// Return the descriptions of supported networks, sorted by URI
+// Chain IDs, aliases and adapters listed by different relay servers
+// for the same network are merged
+// Conflicts are logged and left out, so that requests
+// aren't routed to the wrong network or sent with the wrong protocol:
+// a network with different chain IDs on different relay servers
+// has no chain ID, chain IDs or aliases listed for different
+// networks aren't listed for any of them, and a network with
+// different adapters on different relay servers gets the default
+// JSON-RPC adapter, so that REST paths aren't routed to it
+func (a *Api) NetworkInfos() []cmix.NetworkInfo {
+	a.mux.RLock()
+	byUri := make(map[string]*cmix.NetworkInfo)
+	// Relay servers listing each chain ID, by network URI
+	chainIds := make(map[string]map[uint64][]string)
+	// Relay servers listing each network URI, by alias
+	aliases := make(map[string]map[string][]string)
+	// Relay servers listing each adapter, by network URI
+	adapters := make(map[string]map[cmix.Adapter][]string)
+	for name, r := range a.relayers {
+		for _, info := range r.NetworkInfos() {
+			merged, ok := byUri[info.Uri]
+			if !ok {
+				merged = &cmix.NetworkInfo{Uri: info.Uri}
+				byUri[info.Uri] = merged
+				chainIds[info.Uri] = make(map[uint64][]string)
+				adapters[info.Uri] = make(map[cmix.Adapter][]string)
+			}
+			// An empty adapter is the default JSON-RPC
+			adapter := info.Adapter
+			if adapter == "" {
+				adapter = cmix.AdapterJsonRpc
+			}
+			merged.Adapter = info.Adapter
+			adapters[info.Uri][adapter] = append(adapters[info.Uri][adapter], name)
+			if info.ChainId != 0 {
+				merged.ChainId = info.ChainId
+				chainIds[info.Uri][info.ChainId] = append(chainIds[info.Uri][info.ChainId], name)
+			}
+			for _, alias := range info.Aliases {
+				if !contains(merged.Aliases, alias) {
+					merged.Aliases = append(merged.Aliases, alias)
+				}
+				if aliases[alias] == nil {
+					aliases[alias] = make(map[string][]string)
+				}
+				aliases[alias][info.Uri] = append(aliases[alias][info.Uri], name)
+			}
+		}
+	}
+	a.mux.RUnlock()
+
+	var conflicts []string
+
+	// Chain IDs of a network that differ between relay servers
+	for uri, ids := range chainIds {
+		if len(ids) < 2 {
+			continue
+		}
+		listed := make([]string, 0, len(ids))
+		for id, names := range ids {
+			listed = append(listed, fmt.Sprintf("%d (%s)", id, joinSorted(names)))
+		}
+		conflicts = append(conflicts, fmt.Sprintf("network %s has different chain IDs %s, no chain ID is used to route requests", uri, joinSorted(listed)))
+		byUri[uri].ChainId = 0
+	}
+
+	// Chain IDs listed for different networks
+	byChainId := make(map[uint64][]string)
+	for uri, ids := range chainIds {
+		for id := range ids {
+			byChainId[id] = append(byChainId[id], uri)
+		}
+	}
+	for id, uris := range byChainId {
+		if len(uris) < 2 {
+			continue
+		}
+		conflicts = append(conflicts, fmt.Sprintf("chain ID %d is listed for networks %s, it isn't used to route requests", id, joinSorted(uris)))
+		for _, uri := range uris {
+			byUri[uri].ChainId = 0
+		}
+	}
+
+	// Aliases listed for different networks
+	for alias, uris := range aliases {
+		if len(uris) < 2 {
+			continue
+		}
+		listed := make([]string, 0, len(uris))
+		for uri, names := range uris {
+			listed = append(listed, fmt.Sprintf("%s (%s)", uri, joinSorted(names)))
+			byUri[uri].Aliases = remove(byUri[uri].Aliases, alias)
+		}
+		conflicts = append(conflicts, fmt.Sprintf("alias %s is listed for networks %s, it isn't used to route requests", alias, joinSorted(listed)))
+	}
+
+	// Adapters of a network that differ between relay servers
+	for uri, listed := range adapters {
+		if len(listed) < 2 {
+			continue
+		}
+		names := make([]string, 0, len(listed))
+		for adapter, relays := range listed {
+			names = append(names, fmt.Sprintf("%s (%s)", adapter, joinSorted(relays)))
+		}
+		conflicts = append(conflicts, fmt.Sprintf("network %s has different adapters %s, REST paths aren't routed to it", uri, joinSorted(names)))
+		byUri[uri].Adapter = ""
+	}
+	a.logConflicts(conflicts)
+
+	networks := make([]cmix.NetworkInfo, 0, len(byUri))
+	for _, info := range byUri {
+		networks = append(networks, *info)
+	}
+	sort.Slice(networks, func(i, j int) bool {
+		return networks[i].Uri < networks[j].Uri
+	})
+	return networks
+}
+
+// Log network conflicts between relay servers
+// Each conflict is logged once while it lasts
+func (a *Api) logConflicts(conflicts []string) {
+	a.conflictMux.Lock()
+	defer a.conflictMux.Unlock()
+	current := make(map[string]struct{}, len(conflicts))
+	for _, conflict := range conflicts {
+		current[conflict] = struct{}{}
+		if _, ok := a.conflicts[conflict]; !ok {
+			jww.WARN.Printf("[%s] Relay servers disagree: %s", a.logPrefix, conflict)
+		}
+	}
+	a.conflicts = current
+}
+
+// ---------------------------- //
+// Resolve the network URI of a request path
+// The path can be a network URI (/ethereum/mainnet),
+// a chain ID (/chain/1), an alias (/eth), or / for the
+// default network
+// Paths that don't match a supported network are returned unchanged,
+// as are chain IDs and aliases that relay servers disagree on
+func (a *Api) ResolveNetwork(path string) string {
+	if path == "/" && a.defaultNetwork != "" {
+		path = a.defaultNetwork
+	}
+	if parseCustomUri(path) != "" {
+		return path
+	}
+	networks := a.NetworkInfos()
+	for _, info := range networks {
+		if info.Uri == path {
+			return path
+		}
+	}
+
+	// Chain ID
+	if strings.HasPrefix(path, chainPrefix) {
+		chainId, err := strconv.ParseUint(strings.TrimPrefix(path, chainPrefix), 0, 64)
+		if err != nil || chainId == 0 {
+			return path
+		}
+		for _, info := range networks {
+			if info.ChainId == chainId {
+				return info.Uri
+			}
+		}
+		return path
+	}
+
+	// Alias
+	alias := strings.TrimPrefix(path, "/")
+	for _, info := range networks {
+		if contains(info.Aliases, alias) {
+			return info.Uri
+		}
+	}
+	return path
+}
+
+// Sort a list of names and join them
+func joinSorted(list []string) string {
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
+
+// Return the list without the given item
+func remove(list []string, s string) []string {
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+func newNetworksApi(relays map[string][]cmix.NetworkInfo) *Api {
+	a := &Api{relayers: make(map[string]*Relay)}
+	for name, infos := range relays {
+		a.relayers[name] = &Relay{name: name, infos: infos}
+	}
+	return a
+}
+
+func TestResolveNetwork(t *testing.T) {
+	a := newNetworksApi(map[string][]cmix.NetworkInfo{
+		"relay-1": {
+			{Uri: "/ethereum/mainnet", ChainId: 1, Aliases: []string{"eth"}},
+			{Uri: "/polygon/mainnet", ChainId: 137},
+		},
+		"relay-2": {
+			{Uri: "/ethereum/mainnet", Aliases: []string{"ethereum"}},
+			{Uri: "/polygon/mainnet", Aliases: []string{"matic"}},
+		},
+	})
+	tests := map[string]string{
+		"/ethereum/mainnet": "/ethereum/mainnet",
+		"/chain/1":          "/ethereum/mainnet",
+		"/chain/0x89":       "/polygon/mainnet",
+		"/eth":              "/ethereum/mainnet",
+		"/ethereum":         "/ethereum/mainnet",
+		"/matic":            "/polygon/mainnet",
+		"/chain/5":          "/chain/5",
+		"/unknown":          "/unknown",
+	}
+	for path, want := range tests {
+		if got := a.ResolveNetwork(path); got != want {
+			t.Errorf("ResolveNetwork(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
+
+// Chain IDs and aliases that relay servers disagree on aren't resolved
+func TestResolveNetworkConflicts(t *testing.T) {
+	a := newNetworksApi(map[string][]cmix.NetworkInfo{
+		"relay-1": {
+			{Uri: "/ethereum/mainnet", ChainId: 1, Aliases: []string{"eth", "mainnet"}},
+			{Uri: "/ethereum/goerli", ChainId: 5},
+			{Uri: "/polygon/mainnet", ChainId: 137},
+		},
+		"relay-2": {
+			{Uri: "/ethereum/mainnet", ChainId: 1},
+			{Uri: "/ethereum/goerli", ChainId: 6},
+			{Uri: "/polygon/mainnet", ChainId: 1, Aliases: []string{"mainnet"}},
+		},
+	})
+	for _, path := range []string{"/chain/1", "/chain/5", "/chain/6", "/chain/137", "/mainnet"} {
+		if got := a.ResolveNetwork(path); got != path {
+			t.Errorf("ResolveNetwork(%s) = %s, want unresolved", path, got)
+		}
+	}
+	if got := a.ResolveNetwork("/eth"); got != "/ethereum/mainnet" {
+		t.Errorf("ResolveNetwork(/eth) = %s, want /ethereum/mainnet", got)
+	}
+	for _, info := range a.NetworkInfos() {
+		if info.ChainId != 0 || contains(info.Aliases, "mainnet") {
+			t.Errorf("network %s lists conflicting chain ID %d or aliases %v", info.Uri, info.ChainId, info.Aliases)
+		}
+	}
+	if len(a.conflicts) != 4 {
+		t.Errorf("%d conflicts logged, want 4: %v", len(a.conflicts), a.conflicts)
+	}
+}
+
+func TestRestNetwork(t *testing.T) {
+	a := newNetworksApi(map[string][]cmix.NetworkInfo{
+		"relay-1": {
+			{Uri: "/cosmos/hub", Aliases: []string{"atom"}, Adapter: cmix.AdapterRest},
+			{Uri: "/ethereum/mainnet", ChainId: 1},
+			{Uri: "/osmosis/mainnet", Adapter: cmix.AdapterRest},
+		},
+		"relay-2": {
+			{Uri: "/cosmos/hub", Adapter: cmix.AdapterRest},
+			{Uri: "/osmosis/mainnet"},
+		},
+	})
+	tests := []struct {
+		path    string
+		network string
+		rest    string
+		ok      bool
+	}{
+		{"/cosmos/hub/cosmos/base/tendermint/v1beta1/node_info", "/cosmos/hub", "/cosmos/base/tendermint/v1beta1/node_info", true},
+		{"/atom/cosmos/bank/v1beta1/supply", "/cosmos/hub", "/cosmos/bank/v1beta1/supply", true},
+		{"/cosmos/hub", "/cosmos/hub", "/", true},
+		{"/ethereum/mainnet", "", "", false},
+		// Relay servers disagree on the adapter
+		{"/osmosis/mainnet/cosmos/base/tendermint/v1beta1/node_info", "", "", false},
+	}
+	for _, tt := range tests {
+		network, rest, ok := a.RestNetwork(tt.path)
+		if network != tt.network || rest != tt.rest || ok != tt.ok {
+			t.Errorf("RestNetwork(%s) = %s, %s, %v, want %s, %s, %v", tt.path, network, rest, ok, tt.network, tt.rest, tt.ok)
+		}
+	}
+}
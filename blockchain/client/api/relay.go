@@ -4,8 +4,8 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	jww "github.com/spf13/jwalterweatherman"
@@ -22,32 +22,42 @@ type Relay struct {
 	client    *cmix.Client
 	contact   contact.Contact
 	logPrefix string
-	retries   int
+	retry     RetryPolicy
+	padding   cmix.Padding
+	score     *relayScore
 
 	networks          []string
+	infos             []cmix.NetworkInfo
 	supportedNetworks map[string]struct{}
 	mux               sync.RWMutex
 
-	stopping bool
+	// Status
+	requestCount atomic.Uint64
+	errorCount   atomic.Uint64
+	lastSeen     atomic.Int64
+
 	stopChan chan struct{}
-	cb       func(string, bool)
+	wg       sync.WaitGroup
+	cb       func(*Relay, bool)
 }
 
-func NewRelay(name string, client *cmix.Client, contact contact.Contact, logPrefix string, retries int) *Relay {
+func NewRelay(name string, client *cmix.Client, contact contact.Contact, logPrefix string, retry RetryPolicy, padding cmix.Padding) *Relay {
 	return &Relay{
 		name:      name,
 		client:    client,
 		contact:   contact,
 		logPrefix: logPrefix,
-		retries:   retries,
+		retry:     retry.withDefaults(),
+		padding:   padding,
+		score:     newRelayScore(),
 	}
 }
 
-func (r *Relay) Start(cb func(string, bool)) {
+func (r *Relay) Start(cb func(*Relay, bool)) {
 	r.cb = cb
-	r.stopping = false
 	// Long running task to track relay server
 	r.stopChan = make(chan struct{})
+	r.wg.Add(1)
 	go r.run()
 }
 
@@ -57,6 +67,14 @@ func (r *Relay) Networks() []string {
 	return r.networks
 }
 
+// Get the descriptions of the supported networks,
+// including their chain IDs and aliases if the relay server lists them
+func (r *Relay) NetworkInfos() []cmix.NetworkInfo {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.infos
+}
+
 func (r *Relay) SupportsNetwork(network string) bool {
 	r.mux.RLock()
 	defer r.mux.RUnlock()
@@ -64,19 +82,39 @@ func (r *Relay) SupportsNetwork(network string) bool {
 	return ok
 }
 
+// Stop the long running task and wait for it to return
+// Does nothing if already stopped
 func (r *Relay) Stop() {
-	// Stop the long running task
-	r.stopping = true
-	r.stopChan <- struct{}{}
+	if r.stopChan == nil {
+		return
+	}
 	close(r.stopChan)
+	r.wg.Wait()
+	r.stopChan = nil
+}
+
+func (r *Relay) stopped() bool {
+	select {
+	case <-r.stopChan:
+		return true
+	default:
+		return false
+	}
 }
 
 func (r *Relay) Request(req cmix.Request) ([]byte, int, error) {
+	start := time.Now()
+	// Pad request data, if enabled
+	req.Data = r.padding.Pad(req.Data)
+	r.requestCount.Add(1)
 	response, err := r.client.Request(r.name, r.contact, req)
 	if err != nil {
 		jww.ERROR.Printf("[%s] Error sending request to relay server %s: %v", r.logPrefix, r.name, err)
+		r.score.record(false, 0)
+		r.errorCount.Add(1)
 		return nil, 500, err
 	}
+	r.lastSeen.Store(time.Now().UnixNano())
 
 	// Parse code from headers
 	code := 500
@@ -86,21 +124,31 @@ func (r *Relay) Request(req cmix.Request) ([]byte, int, error) {
 
 	// Parse response error
 	if response.Error != "" {
-		errMsg := fmt.Sprintf("Response error: %v", response.Error)
-		jww.ERROR.Printf("[%s] Relay server %s: %v", r.logPrefix, r.name, errMsg)
-		return nil, code, errors.New(errMsg)
-	} else {
-		return response.Content, code, nil
+		relayErr := &RelayError{Code: code, Msg: response.Error}
+		jww.ERROR.Printf("[%s] Relay server %s: %v", r.logPrefix, r.name, relayErr)
+		// Only server side errors count against the relay server
+		r.score.record(!isRetryable(relayErr), time.Since(start))
+		r.errorCount.Add(1)
+		return nil, code, relayErr
 	}
+
+	// Strip response padding, if present
+	content, _, err := cmix.Unpad(response.Content)
+	if err != nil {
+		jww.ERROR.Printf("[%s] Relay server %s: invalid response padding: %v", r.logPrefix, r.name, err)
+		r.score.record(false, 0)
+		r.errorCount.Add(1)
+		return nil, 500, err
+	}
+	r.score.record(true, time.Since(start))
+	return content, code, nil
 }
 
 func (r *Relay) run() {
+	defer r.wg.Done()
 	ticker := time.NewTicker(60 * time.Second)
+	defer ticker.Stop()
 	r.requestNetworks()
-	// Exit early if stop was called
-	if r.stopping {
-		return
-	}
 	for {
 		select {
 		case <-r.stopChan:
@@ -112,48 +160,44 @@ func (r *Relay) run() {
 }
 
 func (r *Relay) requestNetworks() {
-	// Request networks
+	// Request networks, with chain IDs and aliases
+	// Relay servers that don't support it return network URIs only
+	listing, _ := json.Marshal(cmix.NetworksRequest{Version: cmix.NetworksVersion})
 	req := cmix.Request{
 		Method:  restlike.Get,
 		Uri:     "/networks",
-		Data:    nil,
+		Data:    listing,
 		Headers: nil,
 	}
-	tries := 0
 	var resp []byte
-	var err error = errors.New("dummy")
-	for err != nil {
-		// Check if stop was called and exit right away
-		select {
-		case <-r.stopChan:
-			return
-		default:
-		}
+	err := r.retry.Do(r.stopChan, func(attempt int) error {
+		var err error
 		resp, _, err = r.Request(req)
-		tries++
-		if tries >= r.retries {
-			break
-		}
-	}
+		return err
+	})
 	// Exit early if stop was called
-	if r.stopping {
+	if r.stopped() || errors.Is(err, errRetryStopped) {
 		return
 	}
 	// Couldn't get response, notify callback that relay server is down
 	if err != nil {
-		jww.WARN.Printf("[%s] Failed to contact relay server %s after %v retries", r.logPrefix, r.name, r.retries)
-		r.cb(r.name, false)
+		jww.WARN.Printf("[%s] Failed to contact relay server %s: %v", r.logPrefix, r.name, err)
+		r.cb(r, false)
 		return
 	}
 	// Got response, update supported networks and
 	// notify callback that relay server is up
-	r.mux.Lock()
-	err = json.Unmarshal(resp, &r.networks)
+	infos, err := cmix.ParseNetworks(resp)
 	if err != nil {
 		jww.ERROR.Printf("[%s] Couldn't get supported networks from relay server %s: %v", r.logPrefix, r.name, err)
-		r.mux.Unlock()
 		return
 	}
+	r.mux.Lock()
+	r.infos = infos
+	r.networks = make([]string, len(infos))
+	for i, info := range infos {
+		r.networks[i] = info.Uri
+	}
 
 	// Build map of supported networks for fast lookup
 	for k := range r.supportedNetworks {
@@ -167,5 +211,20 @@ func (r *Relay) requestNetworks() {
 	r.mux.Unlock()
 
 	// Notify callback
-	r.cb(r.name, true)
+	r.cb(r, true)
+}
+
+// Get the status of the relay server
+func (r *Relay) status(active bool) RelayStatus {
+	status := RelayStatus{
+		Name:     r.name,
+		Active:   active,
+		Networks: r.Networks(),
+		Requests: r.requestCount.Load(),
+		Errors:   r.errorCount.Load(),
+	}
+	if lastSeen := r.lastSeen.Load(); lastSeen != 0 {
+		status.LastSeen = time.Unix(0, lastSeen)
+	}
+	return status
 }
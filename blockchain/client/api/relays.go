@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// ---------------------------- //
+// RelayStatus is the status of a relay server
+type RelayStatus struct {
+	Name string
+
+	// Whether the relay server is used for requests
+	Active bool
+
+	// Networks supported by the relay server
+	Networks []string
+
+	// Time of the last response from the relay server
+	// Zero if the relay server never responded
+	LastSeen time.Time
+
+	// Number of requests sent to the relay server,
+	// and how many of them failed
+	Requests uint64
+	Errors   uint64
+}
+
+// Errors of relay server management
+var (
+	ErrRelayExists   = errors.New("relay server already exists")
+	ErrRelayNotFound = errors.New("relay server not found")
+)
+
+// ---------------------------- //
+// Add a relay server
+// The contact is loaded from the contact file, if provided
+// If the API is connected, the relay server is started
+// and becomes active once it returns its supported networks
+func (a *Api) AddRelay(info ServerInfo) error {
+	contact := info.Contact
+	if info.ContactFile != "" {
+		var err error
+		contact, err = cmix.ReadContactFile(info.ContactFile)
+		if err != nil {
+			return err
+		}
+	}
+	relayer := NewRelay(info.Name, a.client, contact, a.logPrefix, a.retry, a.padding)
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if _, ok := a.relayers[info.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrRelayExists, info.Name)
+	}
+	a.relayers[info.Name] = relayer
+	a.active[info.Name] = false
+	if a.connected {
+		relayer.Start(a.updateRelayers)
+	}
+	jww.INFO.Printf("[%s] Added relay server %s", a.logPrefix, info.Name)
+	return nil
+}
+
+// ---------------------------- //
+// Remove a relay server
+// The relay server is no longer used for new requests,
+// and this function returns once it is stopped
+// Requests in progress to the relay server are not interrupted
+func (a *Api) RemoveRelay(name string) error {
+	a.mux.Lock()
+	relayer, ok := a.relayers[name]
+	if !ok {
+		a.mux.Unlock()
+		return fmt.Errorf("%w: %s", ErrRelayNotFound, name)
+	}
+	delete(a.relayers, name)
+	delete(a.active, name)
+	a.mux.Unlock()
+
+	relayer.Stop()
+	jww.INFO.Printf("[%s] Removed relay server %s", a.logPrefix, name)
+	return nil
+}
+
+// ---------------------------- //
+// Return the status of all relay servers, sorted by name
+func (a *Api) Relays() []RelayStatus {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	relays := make([]RelayStatus, 0, len(a.relayers))
+	for name, r := range a.relayers {
+		relays = append(relays, r.status(a.active[name]))
+	}
+	sort.Slice(relays, func(i, j int) bool {
+		return relays[i].Name < relays[j].Name
+	})
+	return relays
+}
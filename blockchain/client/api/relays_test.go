@@ -0,0 +1,30 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// Relay servers stopped by Disconnect can still be removed
+func TestRemoveRelayAfterDisconnect(t *testing.T) {
+	a := &Api{
+		client:    &cmix.Client{},
+		metrics:   &Metrics{},
+		relayers:  make(map[string]*Relay),
+		active:    make(map[string]bool),
+		connected: true,
+		stopChan:  make(chan struct{}),
+	}
+	a.cover = newCoverTraffic(a, CoverConfig{})
+	if err := a.AddRelay(ServerInfo{Name: "relay-1"}); err != nil {
+		t.Fatalf("AddRelay() error: %v", err)
+	}
+	a.Disconnect()
+	if err := a.RemoveRelay("relay-1"); err != nil {
+		t.Fatalf("RemoveRelay() error: %v", err)
+	}
+	if len(a.Relays()) != 0 {
+		t.Errorf("Relays() = %v, want none", a.Relays())
+	}
+}
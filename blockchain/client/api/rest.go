@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+	"gitlab.com/elixxir/client/v4/restlike"
+)
+
+// ---------------------------- //
+// Split a request path into a REST network URI and the path
+// within the network, such as /cosmos/hub/cosmos/base/tendermint/v1beta1/node_info
+// into /cosmos/hub and /cosmos/base/tendermint/v1beta1/node_info
+// The network can be given as a URI, chain ID path or alias,
+// and the longest match is used
+// Returns false if the path isn't in a REST network
+func (a *Api) RestNetwork(path string) (string, string, bool) {
+	network, rest, matched := "", "", 0
+	for _, info := range a.NetworkInfos() {
+		if info.Adapter != cmix.AdapterRest {
+			continue
+		}
+		prefixes := []string{info.Uri}
+		if info.ChainId != 0 {
+			prefixes = append(prefixes, fmt.Sprintf("%s%d", chainPrefix, info.ChainId))
+		}
+		for _, alias := range info.Aliases {
+			prefixes = append(prefixes, "/"+alias)
+		}
+		for _, prefix := range prefixes {
+			if len(prefix) <= matched {
+				continue
+			}
+			if path == prefix {
+				network, rest, matched = info.Uri, "/", len(prefix)
+			} else if strings.HasPrefix(path, prefix+"/") {
+				network, rest, matched = info.Uri, strings.TrimPrefix(path, prefix), len(prefix)
+			}
+		}
+	}
+	return network, rest, matched > 0
+}
+
+// ---------------------------- //
+// Do a Request over cMix to the given REST network
+// with the given HTTP method, path within the network, and body
+// Returns response data, code and possible error
+func (a *Api) RestRequest(network, method, path string, data []byte) ([]byte, int, error) {
+	headers, err := json.Marshal(cmix.RestRequest{
+		Method: method,
+		Path:   path,
+	})
+	if err != nil {
+		return nil, 500, err
+	}
+	return a.doRequest(restlike.Post, network, data, headers, requestOptions{})
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// ---------------------------- //
+// RetryPolicy controls how requests
+// over cMix are retried on failure
+// Delays between attempts grow exponentially
+// and are randomized with jitter
+type RetryPolicy struct {
+	// Maximum number of attempts
+	MaxRetries int
+
+	// Delay before the first retry
+	InitialDelay time.Duration
+
+	// Upper bound for the delay between attempts
+	MaxDelay time.Duration
+
+	// Factor by which the delay grows on each attempt
+	Multiplier float64
+
+	// Randomization factor applied to each delay (0 = no jitter, 1 = full jitter)
+	Jitter float64
+
+	// Maximum total time spent retrying (0 = no limit)
+	MaxElapsed time.Duration
+}
+
+// Default retry policy values
+const (
+	defaultMaxRetries   = 3
+	defaultInitialDelay = 500 * time.Millisecond
+	defaultMaxDelay     = 10 * time.Second
+	defaultMultiplier   = 2.0
+	defaultJitter       = 0.5
+	defaultMaxElapsed   = 2 * time.Minute
+)
+
+// ---------------------------- //
+// Return the default retry policy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:   defaultMaxRetries,
+		InitialDelay: defaultInitialDelay,
+		MaxDelay:     defaultMaxDelay,
+		Multiplier:   defaultMultiplier,
+		Jitter:       defaultJitter,
+		MaxElapsed:   defaultMaxElapsed,
+	}
+}
+
+// ---------------------------- //
+// Return a copy of the policy with
+// unset fields filled with default values
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxRetries <= 0 {
+		p.MaxRetries = defaultMaxRetries
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = defaultInitialDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultMaxDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaultMultiplier
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		p.Jitter = defaultJitter
+	}
+	return p
+}
+
+// ---------------------------- //
+// Return the delay to wait before
+// the given retry attempt (starting at 1)
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := float64(p.InitialDelay)
+	for i := 1; i < attempt; i++ {
+		delay *= p.Multiplier
+		if delay >= float64(p.MaxDelay) {
+			delay = float64(p.MaxDelay)
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		// Randomize delay in [delay*(1-jitter), delay*(1+jitter)]
+		delta := p.Jitter * delay
+		delay = delay - delta + rand.Float64()*(2*delta)
+	}
+	if delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	return time.Duration(delay)
+}
+
+// ---------------------------- //
+// Run fn until it succeeds, returns a non-retryable
+// error, the number of retries is exhausted,
+// the maximum elapsed time is reached
+// or the stop channel is closed
+// The attempt number (starting at 0) is passed to fn
+// Returns the last error from fn
+func (p RetryPolicy) Do(stop <-chan struct{}, fn func(attempt int) error) error {
+	start := time.Now()
+	var err error
+	for attempt := 0; attempt < p.MaxRetries; attempt++ {
+		// Wait before retrying
+		if attempt > 0 {
+			delay := p.Delay(attempt)
+			if p.MaxElapsed > 0 && time.Since(start)+delay > p.MaxElapsed {
+				return fmt.Errorf("%w: %v", errRetryElapsed, err)
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-stop:
+				timer.Stop()
+				return errRetryStopped
+			case <-timer.C:
+			}
+		}
+		if err = fn(attempt); err == nil || !isRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// ---------------------------- //
+// Errors
+// ---------------------------- //
+
+var errRetryElapsed = errors.New("maximum retry time elapsed")
+var errRetryStopped = errors.New("retry stopped")
+
+// RelayError is an error returned by a relay server in its response
+// It carries the response code sent by the relay server
+type RelayError struct {
+	Code int
+	Msg  string
+}
+
+func (e *RelayError) Error() string {
+	return fmt.Sprintf("Response error: %v", e.Msg)
+}
+
+// Classify an error as retryable or not
+// Errors sending over cMix are retryable, as well as
+// relay server errors with a 5xx code
+// Relay server errors with a 4xx code are caused by the
+// request itself, so retrying it won't help
+func isRetryable(err error) bool {
+	if errors.Is(err, errRetryStopped) ||
+		errors.Is(err, cmix.ErrShuttingDown) ||
+		errors.Is(err, ErrVerificationFailed) ||
+		errors.Is(err, errNotEnoughRelayers) {
+		return false
+	}
+	var relayErr *RelayError
+	if errors.As(err, &relayErr) {
+		return relayErr.Code >= 500
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"errors"
+	"math/big"
+)
+
+// ---------------------------- //
+// Minimal RLP (Recursive Length Prefix) encoding
+// as used by Ethereum, needed to verify
+// block headers and Merkle-Patricia proofs
+
+// rlpItem is a decoded RLP item
+// It is either a byte string or a list of items
+type rlpItem struct {
+	list  bool
+	data  []byte
+	items []rlpItem
+}
+
+var errRlpInvalid = errors.New("invalid RLP encoding")
+
+// Decode a single RLP item, which must span all data
+func rlpDecode(data []byte) (rlpItem, error) {
+	item, rest, err := rlpDecodeItem(data)
+	if err != nil {
+		return rlpItem{}, err
+	}
+	if len(rest) != 0 {
+		return rlpItem{}, errRlpInvalid
+	}
+	return item, nil
+}
+
+// Decode the first RLP item in data
+// Returns the item and the remaining data
+func rlpDecodeItem(data []byte) (rlpItem, []byte, error) {
+	if len(data) == 0 {
+		return rlpItem{}, nil, errRlpInvalid
+	}
+	prefix := data[0]
+	switch {
+	case prefix < 0x80:
+		// Single byte
+		return rlpItem{data: data[:1]}, data[1:], nil
+	case prefix < 0xb8:
+		// Short string
+		return rlpString(data[1:], int(prefix-0x80))
+	case prefix < 0xc0:
+		// Long string
+		size, rest, err := rlpLength(data[1:], int(prefix-0xb7))
+		if err != nil {
+			return rlpItem{}, nil, err
+		}
+		return rlpString(rest, size)
+	case prefix < 0xf8:
+		// Short list
+		return rlpList(data[1:], int(prefix-0xc0))
+	default:
+		// Long list
+		size, rest, err := rlpLength(data[1:], int(prefix-0xf7))
+		if err != nil {
+			return rlpItem{}, nil, err
+		}
+		return rlpList(rest, size)
+	}
+}
+
+func rlpString(data []byte, size int) (rlpItem, []byte, error) {
+	if size > len(data) {
+		return rlpItem{}, nil, errRlpInvalid
+	}
+	return rlpItem{data: data[:size]}, data[size:], nil
+}
+
+func rlpList(data []byte, size int) (rlpItem, []byte, error) {
+	if size > len(data) {
+		return rlpItem{}, nil, errRlpInvalid
+	}
+	content := data[:size]
+	list := rlpItem{list: true, items: make([]rlpItem, 0)}
+	for len(content) > 0 {
+		item, rest, err := rlpDecodeItem(content)
+		if err != nil {
+			return rlpItem{}, nil, err
+		}
+		list.items = append(list.items, item)
+		content = rest
+	}
+	return list, data[size:], nil
+}
+
+// Read a big endian length of lenSize bytes
+func rlpLength(data []byte, lenSize int) (int, []byte, error) {
+	if lenSize > len(data) || lenSize > 8 {
+		return 0, nil, errRlpInvalid
+	}
+	size := 0
+	for _, b := range data[:lenSize] {
+		size = size<<8 | int(b)
+	}
+	if size < 0 {
+		return 0, nil, errRlpInvalid
+	}
+	return size, data[lenSize:], nil
+}
+
+// Encode a byte string
+func rlpEncodeBytes(data []byte) []byte {
+	if len(data) == 1 && data[0] < 0x80 {
+		return []byte{data[0]}
+	}
+	return append(rlpHeader(0x80, len(data)), data...)
+}
+
+// Encode an unsigned integer, as a big endian
+// byte string without leading zeros
+func rlpEncodeUint(value *big.Int) []byte {
+	return rlpEncodeBytes(value.Bytes())
+}
+
+// Encode a list of already encoded items
+func rlpEncodeList(items ...[]byte) []byte {
+	size := 0
+	for _, item := range items {
+		size += len(item)
+	}
+	encoded := rlpHeader(0xc0, size)
+	for _, item := range items {
+		encoded = append(encoded, item...)
+	}
+	return encoded
+}
+
+// Encode the header of a string (offset 0x80) or list (offset 0xc0)
+func rlpHeader(offset byte, size int) []byte {
+	if size < 56 {
+		return []byte{offset + byte(size)}
+	}
+	sizeBytes := big.NewInt(int64(size)).Bytes()
+	return append([]byte{offset + 55 + byte(len(sizeBytes))}, sizeBytes...)
+}
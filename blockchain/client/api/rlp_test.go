@@ -0,0 +1,149 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// Examples of the RLP specification
+func TestRlpEncode(t *testing.T) {
+	lorem := "Lorem ipsum dolor sit amet, consectetur adipisicing elit"
+	tests := []struct {
+		name    string
+		encoded []byte
+		want    string
+	}{
+		{"dog", rlpEncodeBytes([]byte("dog")), "0x83646f67"},
+		{"cat dog", rlpEncodeList(rlpEncodeBytes([]byte("cat")), rlpEncodeBytes([]byte("dog"))), "0xc88363617483646f67"},
+		{"empty string", rlpEncodeBytes(nil), "0x80"},
+		{"empty list", rlpEncodeList(), "0xc0"},
+		{"zero", rlpEncodeUint(big.NewInt(0)), "0x80"},
+		{"byte 0x00", rlpEncodeBytes([]byte{0x00}), "0x00"},
+		{"byte 0x0f", rlpEncodeBytes([]byte{0x0f}), "0x0f"},
+		{"bytes 0x0400", rlpEncodeUint(big.NewInt(1024)), "0x820400"},
+		{"byte 0x80", rlpEncodeBytes([]byte{0x80}), "0x8180"},
+		{
+			"set theoretic three",
+			rlpEncodeList(
+				rlpEncodeList(),
+				rlpEncodeList(rlpEncodeList()),
+				rlpEncodeList(rlpEncodeList(), rlpEncodeList(rlpEncodeList())),
+			),
+			"0xc7c0c1c0c3c0c1c0",
+		},
+		{"long string", rlpEncodeBytes([]byte(lorem)), "0xb838" + hex.EncodeToString([]byte(lorem))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !bytes.Equal(tt.encoded, mustHex(t, tt.want)) {
+				t.Errorf("encoded = %x, want %s", tt.encoded, tt.want)
+			}
+		})
+	}
+}
+
+func TestRlpDecode(t *testing.T) {
+	lorem := "Lorem ipsum dolor sit amet, consectetur adipisicing elit"
+	tests := []struct {
+		name    string
+		encoded string
+		want    rlpItem
+	}{
+		{"dog", "0x83646f67", rlpItem{data: []byte("dog")}},
+		{"single byte", "0x0f", rlpItem{data: []byte{0x0f}}},
+		{"empty string", "0x80", rlpItem{data: []byte{}}},
+		{"empty list", "0xc0", rlpItem{list: true, items: []rlpItem{}}},
+		{"long string", "0xb838" + hex.EncodeToString([]byte(lorem)), rlpItem{data: []byte(lorem)}},
+		{
+			"cat dog",
+			"0xc88363617483646f67",
+			rlpItem{list: true, items: []rlpItem{{data: []byte("cat")}, {data: []byte("dog")}}},
+		},
+		{
+			"nested",
+			"0xc7c0c1c0c3c0c1c0",
+			rlpItem{list: true, items: []rlpItem{
+				{list: true, items: []rlpItem{}},
+				{list: true, items: []rlpItem{{list: true, items: []rlpItem{}}}},
+				{list: true, items: []rlpItem{
+					{list: true, items: []rlpItem{}},
+					{list: true, items: []rlpItem{{list: true, items: []rlpItem{}}}},
+				}},
+			}},
+		},
+		{
+			"long list",
+			"0xf83c" + strings.Repeat("83646f67", 15),
+			rlpItem{list: true, items: []rlpItem{
+				{data: []byte("dog")}, {data: []byte("dog")}, {data: []byte("dog")}, {data: []byte("dog")}, {data: []byte("dog")},
+				{data: []byte("dog")}, {data: []byte("dog")}, {data: []byte("dog")}, {data: []byte("dog")}, {data: []byte("dog")},
+				{data: []byte("dog")}, {data: []byte("dog")}, {data: []byte("dog")}, {data: []byte("dog")}, {data: []byte("dog")},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rlpDecode(mustHex(t, tt.encoded))
+			if err != nil {
+				t.Fatalf("rlpDecode() error: %v", err)
+			}
+			if !equalRlpItems(got, tt.want) {
+				t.Errorf("rlpDecode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRlpDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty input", "0x"},
+		{"truncated string", "0x83646f"},
+		{"truncated list", "0xc88363617483646f"},
+		{"trailing data", "0x83646f6700"},
+		{"truncated long string length", "0xb9"},
+		{"long string longer than data", "0xb90400" + strings.Repeat("00", 10)},
+		{"long list longer than data", "0xf90400c0"},
+		{"length too large", "0xbfffffffffffffffff"},
+		{"invalid item in list", "0xc283"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := rlpDecode(mustHex(t, tt.encoded)); err == nil {
+				t.Errorf("rlpDecode() = %+v, want error", got)
+			}
+		})
+	}
+}
+
+func TestRlpRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, 55, 56, 255, 256, 1024, 70000} {
+		data := bytes.Repeat([]byte{0xab}, size)
+		encoded := rlpEncodeList(rlpEncodeBytes(data), rlpEncodeBytes([]byte{0x01}))
+		item, err := rlpDecode(encoded)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !item.list || len(item.items) != 2 || !bytes.Equal(item.items[0].data, data) ||
+			!bytes.Equal(item.items[1].data, []byte{0x01}) {
+			t.Errorf("size %d: decoded %+v", size, item)
+		}
+	}
+}
+
+func equalRlpItems(a, b rlpItem) bool {
+	if a.list != b.list || !bytes.Equal(a.data, b.data) || len(a.items) != len(b.items) {
+		return false
+	}
+	for i := range a.items {
+		if !equalRlpItems(a.items[i], b.items[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------- //
+// SanitizeConfig controls removal of data that can identify
+// the user from JSON-RPC requests before they are sent
+// Requests that are not valid JSON-RPC are sent unchanged
+type SanitizeConfig struct {
+	// Replace request ids with sequential ids starting at 1,
+	// and restore the original ids in the response
+	NormalizeIds bool
+
+	// Remove optional fields that can identify the user,
+	// such as the from field of eth_call
+	StripFields bool
+
+	// Methods answered locally with an error instead of being sent
+	BlockedMethods []string
+}
+
+// Methods that expose the accounts of the user
+// Suggested value for BlockedMethods
+var IdentifyingMethods = []string{
+	"eth_accounts",
+	"eth_coinbase",
+	"eth_sign",
+	"eth_signTransaction",
+	"eth_signTypedData",
+	"personal_listAccounts",
+	"personal_sign",
+}
+
+// Optional fields removed from the first parameter of each method
+var strippedFields = map[string][]string{
+	"eth_call":             {"from"},
+	"eth_estimateGas":      {"from"},
+	"eth_createAccessList": {"from"},
+}
+
+// JSON-RPC error code of blocked methods
+const blockedErrorCode = -32601
+
+// Return true if the configuration changes any requests
+func (s SanitizeConfig) Enabled() bool {
+	return s.NormalizeIds || s.StripFields || len(s.BlockedMethods) > 0
+}
+
+func (s SanitizeConfig) isBlocked(method string) bool {
+	for _, blocked := range s.BlockedMethods {
+		if method == blocked {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizedRequest holds a sanitized request and the information
+// needed to restore the response for the original request
+type sanitizedRequest struct {
+	// Data to send, nil if all requests were blocked
+	data  []byte
+	batch bool
+
+	// Original id for each normalized id
+	ids map[string]json.RawMessage
+
+	// Local responses to blocked requests
+	blocked []json.RawMessage
+
+	// Description of changes, for logging
+	changes []string
+}
+
+// ---------------------------- //
+// Sanitize JSON-RPC request data
+// Returns nil if the data is not a JSON-RPC request
+func (s SanitizeConfig) sanitize(data []byte) *sanitizedRequest {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+	req := &sanitizedRequest{
+		batch: data[0] == '[',
+		ids:   make(map[string]json.RawMessage),
+	}
+
+	// Parse requests as generic objects, so that unknown fields are kept
+	var calls []map[string]json.RawMessage
+	if req.batch {
+		if err := json.Unmarshal(data, &calls); err != nil {
+			return nil
+		}
+	} else {
+		var call map[string]json.RawMessage
+		if err := json.Unmarshal(data, &call); err != nil || call == nil {
+			return nil
+		}
+		calls = []map[string]json.RawMessage{call}
+	}
+
+	sanitized := make([]map[string]json.RawMessage, 0, len(calls))
+	for _, call := range calls {
+		var method string
+		if err := json.Unmarshal(call["method"], &method); err != nil {
+			return nil
+		}
+		id, hasId := call["id"]
+
+		// Answer blocked methods locally
+		// Notifications of blocked methods are dropped without response
+		if s.isBlocked(method) {
+			req.changes = append(req.changes, "blocked "+method)
+			if hasId {
+				resp, err := json.Marshal(jsonRpcResponse{
+					Jsonrpc: "2.0",
+					Id:      id,
+					Error: &jsonRpcError{
+						Code:    blockedErrorCode,
+						Message: fmt.Sprintf("method %s is blocked by the client", method),
+					},
+				})
+				if err != nil {
+					return nil
+				}
+				req.blocked = append(req.blocked, resp)
+			}
+			continue
+		}
+
+		if s.StripFields {
+			for _, field := range stripFields(call, method) {
+				req.changes = append(req.changes, fmt.Sprintf("removed %s field of %s", field, method))
+			}
+		}
+
+		if s.NormalizeIds && hasId {
+			normalized := strconv.Itoa(len(req.ids) + 1)
+			req.ids[normalized] = id
+			call["id"] = json.RawMessage(normalized)
+		}
+		sanitized = append(sanitized, call)
+	}
+	if s.NormalizeIds && len(req.ids) > 0 {
+		req.changes = append(req.changes, fmt.Sprintf("normalized %d ids", len(req.ids)))
+	}
+
+	// All requests were blocked
+	if len(sanitized) == 0 {
+		return req
+	}
+
+	var err error
+	if req.batch {
+		req.data, err = json.Marshal(sanitized)
+	} else {
+		req.data, err = json.Marshal(sanitized[0])
+	}
+	if err != nil {
+		return nil
+	}
+	return req
+}
+
+// Remove optional fields from the first parameter of the call
+// Returns the names of the removed fields
+func stripFields(call map[string]json.RawMessage, method string) []string {
+	fields, ok := strippedFields[method]
+	if !ok {
+		return nil
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(call["params"], &params); err != nil || len(params) == 0 {
+		return nil
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(params[0], &object); err != nil {
+		return nil
+	}
+	removed := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if _, ok := object[field]; ok {
+			delete(object, field)
+			removed = append(removed, field)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	first, err := json.Marshal(object)
+	if err != nil {
+		return nil
+	}
+	params[0] = first
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return nil
+	}
+	call["params"] = encoded
+	return removed
+}
+
+// ---------------------------- //
+// Restore the original ids in the response data
+// and add responses to blocked requests
+// Data that is not a JSON-RPC response is returned unchanged
+func (r *sanitizedRequest) restore(data []byte) []byte {
+	// All requests were blocked
+	// Notifications get no response
+	if r.data == nil {
+		if len(r.blocked) == 0 {
+			return nil
+		}
+		if !r.batch {
+			return r.blocked[0]
+		}
+		resp, err := json.Marshal(r.blocked)
+		if err != nil {
+			return nil
+		}
+		return resp
+	}
+
+	if !r.batch {
+		var resp map[string]json.RawMessage
+		if err := json.Unmarshal(data, &resp); err != nil || resp == nil {
+			return data
+		}
+		r.restoreId(resp)
+		restored, err := json.Marshal(resp)
+		if err != nil {
+			return data
+		}
+		return restored
+	}
+
+	var batch []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return data
+	}
+	responses := make([]json.RawMessage, 0, len(batch)+len(r.blocked))
+	for _, resp := range batch {
+		r.restoreId(resp)
+		restored, err := json.Marshal(resp)
+		if err != nil {
+			return data
+		}
+		responses = append(responses, restored)
+	}
+	responses = append(responses, r.blocked...)
+	restored, err := json.Marshal(responses)
+	if err != nil {
+		return data
+	}
+	return restored
+}
+
+func (r *sanitizedRequest) restoreId(resp map[string]json.RawMessage) {
+	id, ok := resp["id"]
+	if !ok {
+		return
+	}
+	if original, ok := r.ids[string(bytes.TrimSpace(id))]; ok {
+		resp["id"] = original
+	}
+}
+
+// Describe the changes made to the request
+func (r *sanitizedRequest) report() string {
+	return strings.Join(r.changes, ", ")
+}
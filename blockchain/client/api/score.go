@@ -0,0 +1,66 @@
+package api
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ---------------------------- //
+// relayScore tracks the recent health of a relay server
+// using exponentially weighted moving averages
+// of the request success rate and latency
+type relayScore struct {
+	successRate float64
+	latency     time.Duration
+	mux         sync.Mutex
+}
+
+// Weight given to the most recent request
+const scoreAlpha = 0.3
+
+// Latency assumed for a relay server without any requests
+const scoreInitialLatency = 5 * time.Second
+
+func newRelayScore() *relayScore {
+	return &relayScore{
+		successRate: 1,
+		latency:     scoreInitialLatency,
+	}
+}
+
+// Record the outcome of a request
+// Latency is only tracked for successful requests
+func (s *relayScore) record(success bool, latency time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	result := 0.0
+	if success {
+		result = 1
+		s.latency = time.Duration(scoreAlpha*float64(latency) + (1-scoreAlpha)*float64(s.latency))
+	}
+	s.successRate = scoreAlpha*result + (1-scoreAlpha)*s.successRate
+}
+
+// Return the score value
+// Higher is better: success rate penalized by latency
+func (s *relayScore) value() float64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.successRate / (1 + s.latency.Seconds()/scoreInitialLatency.Seconds())
+}
+
+// Sort relayers by score, from best to worst
+// A small random factor is applied to each score,
+// so that relayers with similar scores share the load
+// and recovered relayers get a chance to be used again
+func sortByScore(relayers []*Relay) {
+	scores := make(map[*Relay]float64, len(relayers))
+	for _, r := range relayers {
+		scores[r] = r.score.value() * (0.9 + 0.2*rand.Float64())
+	}
+	sort.SliceStable(relayers, func(i, j int) bool {
+		return scores[relayers[i]] > scores[relayers[j]]
+	})
+}
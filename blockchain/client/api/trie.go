@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ---------------------------- //
+// Merkle-Patricia trie proof verification
+// as returned by eth_getProof
+
+// Root hash of an empty trie: keccak256(rlp(""))
+var emptyTrieRoot = keccak256([]byte{0x80})
+
+// Hash of empty code: keccak256("")
+var emptyCodeHash = keccak256(nil)
+
+var errProofIncomplete = errors.New("proof is incomplete")
+var errProofHash = errors.New("proof node hash mismatch")
+var errProofNode = errors.New("invalid proof node")
+
+// Compute the Keccak-256 hash of the data
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// Verify a Merkle-Patricia proof for the given key
+// in a secure trie (where keys are hashed) with the given root
+// Returns the value stored for the key, or nil if the
+// proof shows the key is not present in the trie
+// Returns an error if the proof is invalid
+func verifyProof(root []byte, key []byte, proof [][]byte) ([]byte, error) {
+	// Empty trie doesn't have any keys
+	if bytes.Equal(root, emptyTrieRoot) && len(proof) == 0 {
+		return nil, nil
+	}
+
+	nibbles := keyToNibbles(keccak256(key))
+	wantHash := root
+	var node rlpItem
+	next := 0
+	needHash := true
+	for {
+		// Load next node from proof and check its hash
+		if needHash {
+			if next >= len(proof) {
+				return nil, errProofIncomplete
+			}
+			raw := proof[next]
+			next++
+			if !bytes.Equal(keccak256(raw), wantHash) {
+				return nil, errProofHash
+			}
+			var err error
+			if node, err = rlpDecode(raw); err != nil {
+				return nil, err
+			}
+		}
+		if !node.list {
+			return nil, errProofNode
+		}
+
+		var child rlpItem
+		switch len(node.items) {
+		case 17:
+			// Branch node
+			if len(nibbles) == 0 {
+				return nonEmpty(node.items[16].data), nil
+			}
+			child = node.items[nibbles[0]]
+			nibbles = nibbles[1:]
+		case 2:
+			// Extension or leaf node
+			path, leaf, err := decodeCompactPath(node.items[0].data)
+			if err != nil {
+				return nil, err
+			}
+			if leaf {
+				if bytes.Equal(path, nibbles) {
+					return nonEmpty(node.items[1].data), nil
+				}
+				// Different leaf, key is not present
+				return nil, nil
+			}
+			if !bytes.HasPrefix(nibbles, path) {
+				// Path diverges, key is not present
+				return nil, nil
+			}
+			nibbles = nibbles[len(path):]
+			child = node.items[1]
+		default:
+			return nil, errProofNode
+		}
+
+		// Follow the child reference
+		switch {
+		case child.list:
+			// Node embedded in its parent
+			node = child
+			needHash = false
+		case len(child.data) == 0:
+			// Empty child, key is not present
+			return nil, nil
+		case len(child.data) == 32:
+			wantHash = child.data
+			needHash = true
+		default:
+			return nil, errProofNode
+		}
+	}
+}
+
+// Split a key into nibbles (half bytes)
+func keyToNibbles(key []byte) []byte {
+	nibbles := make([]byte, 2*len(key))
+	for i, b := range key {
+		nibbles[2*i] = b >> 4
+		nibbles[2*i+1] = b & 0x0f
+	}
+	return nibbles
+}
+
+// Decode a hex prefix encoded path
+// Returns the path nibbles and whether the node is a leaf
+func decodeCompactPath(compact []byte) ([]byte, bool, error) {
+	if len(compact) == 0 {
+		return nil, false, errProofNode
+	}
+	nibbles := keyToNibbles(compact)
+	flag := nibbles[0]
+	if flag > 3 {
+		return nil, false, errProofNode
+	}
+	leaf := flag&2 != 0
+	if flag&1 != 0 {
+		// Odd length, path starts after the flag nibble
+		return nibbles[1:], leaf, nil
+	}
+	// Even length, skip flag and padding nibbles
+	return nibbles[2:], leaf, nil
+}
+
+// Return nil for empty values
+func nonEmpty(data []byte) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,366 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sort"
+	"testing"
+)
+
+// ---------------------------- //
+// Reference Merkle-Patricia trie builder
+//
+// Builds a trie from its key-value pairs and collects the proof of a key,
+// in the order returned by eth_getProof. The builder is checked against
+// the roots of the Ethereum trie test vectors, so that proofs generated
+// with it can be used to test verifyProof
+
+type trieEntry struct {
+	nibbles []byte
+	value   []byte
+}
+
+type proofNode struct {
+	depth int
+	node  []byte
+}
+
+type testTrie struct {
+	secure  bool
+	entries map[string][]byte
+}
+
+func newTestTrie(secure bool) *testTrie {
+	return &testTrie{secure: secure, entries: make(map[string][]byte)}
+}
+
+func (t *testTrie) put(key, value []byte) {
+	t.entries[string(key)] = value
+}
+
+// Return the root hash of the trie, and the proof of key
+func (t *testTrie) prove(key []byte) ([]byte, [][]byte) {
+	entries := make([]trieEntry, 0, len(t.entries))
+	for k, v := range t.entries {
+		entries = append(entries, trieEntry{t.path([]byte(k)), v})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].nibbles, entries[j].nibbles) < 0
+	})
+	var target []byte
+	if key != nil {
+		target = t.path(key)
+	}
+	nodes := make([]proofNode, 0)
+	root := buildTrieNode(entries, 0, target, &nodes)
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].depth < nodes[j].depth })
+	proof := make([][]byte, len(nodes))
+	for i, n := range nodes {
+		proof[i] = n.node
+	}
+	// The root node is always referenced by its hash
+	if len(proof) == 0 || !bytes.Equal(proof[0], root) {
+		proof = append([][]byte{root}, proof...)
+	}
+	return keccak256(root), proof
+}
+
+func (t *testTrie) path(key []byte) []byte {
+	if t.secure {
+		return keyToNibbles(keccak256(key))
+	}
+	return keyToNibbles(key)
+}
+
+// Build the node of the entries, whose first depth nibbles are shared
+// Nodes on the path of target referenced by hash are added to the proof
+func buildTrieNode(entries []trieEntry, depth int, target []byte, proof *[]proofNode) []byte {
+	if len(entries) == 0 {
+		return rlpEncodeBytes(nil)
+	}
+	var node []byte
+	if len(entries) == 1 {
+		node = rlpEncodeList(
+			rlpEncodeBytes(compactPath(entries[0].nibbles[depth:], true)),
+			rlpEncodeBytes(entries[0].value),
+		)
+	} else if prefix := sharedPrefix(entries, depth); prefix > 0 {
+		child := buildTrieNode(entries, depth+prefix, target, proof)
+		node = rlpEncodeList(
+			rlpEncodeBytes(compactPath(entries[0].nibbles[depth:depth+prefix], false)),
+			nodeReference(child),
+		)
+	} else {
+		items := make([][]byte, 17)
+		items[16] = rlpEncodeBytes(nil)
+		rest := entries
+		if len(rest[0].nibbles) == depth {
+			items[16] = rlpEncodeBytes(rest[0].value)
+			rest = rest[1:]
+		}
+		for nibble := byte(0); nibble < 16; nibble++ {
+			group := make([]trieEntry, 0)
+			for _, e := range rest {
+				if e.nibbles[depth] == nibble {
+					group = append(group, e)
+				}
+			}
+			items[nibble] = nodeReference(buildTrieNode(group, depth+1, target, proof))
+		}
+		node = rlpEncodeList(items...)
+	}
+	if target != nil && bytes.HasPrefix(target, entries[0].nibbles[:depth]) && len(node) >= 32 {
+		*proof = append(*proof, proofNode{depth, node})
+	}
+	return node
+}
+
+// Nodes shorter than a hash are embedded in their parent
+func nodeReference(node []byte) []byte {
+	if len(node) < 32 {
+		return node
+	}
+	return rlpEncodeBytes(keccak256(node))
+}
+
+func sharedPrefix(entries []trieEntry, depth int) int {
+	first := entries[0].nibbles[depth:]
+	shared := len(first)
+	for _, e := range entries[1:] {
+		n := 0
+		for n < shared && n < len(e.nibbles)-depth && e.nibbles[depth+n] == first[n] {
+			n++
+		}
+		shared = n
+	}
+	return shared
+}
+
+// Hex prefix encoding of a path
+func compactPath(nibbles []byte, leaf bool) []byte {
+	flag := byte(0)
+	if leaf {
+		flag = 2
+	}
+	if len(nibbles)%2 == 1 {
+		nibbles = append([]byte{flag + 1}, nibbles...)
+	} else {
+		nibbles = append([]byte{flag, 0}, nibbles...)
+	}
+	compact := make([]byte, len(nibbles)/2)
+	for i := range compact {
+		compact[i] = nibbles[2*i]<<4 | nibbles[2*i+1]
+	}
+	return compact
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := decodeHex(s)
+	if err != nil {
+		t.Fatalf("invalid hex %s: %v", s, err)
+	}
+	return b
+}
+
+// ---------------------------- //
+// Roots of the Ethereum trie test vectors (trietest.json and trieanyorder.json)
+func TestTrieBuilderVectors(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries map[string]string
+		root    string
+	}{
+		{
+			name:    "emptyTrie",
+			entries: map[string]string{},
+			root:    "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+		},
+		{
+			name:    "dogs",
+			entries: map[string]string{"doe": "reindeer", "dog": "puppy", "dogglesworth": "cat"},
+			root:    "0x8aad789dff2f538bca5d8ea56e8abe10f4c7ba3a5dea95fea4cd6e7c3a1168d3",
+		},
+		{
+			name:    "puppy",
+			entries: map[string]string{"do": "verb", "horse": "stallion", "doge": "coin", "dog": "puppy"},
+			root:    "0x5991bb8c6514148a29db676a14ac506cd2cd5775ace63c30a4fe457715e9ac84",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trie := newTestTrie(false)
+			for k, v := range tt.entries {
+				trie.put([]byte(k), []byte(v))
+			}
+			root, _ := trie.prove(nil)
+			if !bytes.Equal(root, mustHex(t, tt.root)) {
+				t.Errorf("root = %x, want %s", root, tt.root)
+			}
+		})
+	}
+}
+
+// ---------------------------- //
+// State trie with accounts of different shapes, with
+// enough keys to have branch, extension and embedded nodes
+func testStateTrie(t *testing.T) (*testTrie, map[string]*account) {
+	t.Helper()
+	accounts := make(map[string]*account)
+	trie := newTestTrie(true)
+	for i := 0; i < 40; i++ {
+		address := keccak256([]byte{byte(i)})[:20]
+		acc := &account{
+			nonce:       big.NewInt(int64(i)),
+			balance:     new(big.Int).Lsh(big.NewInt(int64(i+1)), uint(i)),
+			storageRoot: emptyTrieRoot,
+			codeHash:    emptyCodeHash,
+		}
+		if i%3 == 0 {
+			acc.codeHash = keccak256([]byte{0x60, byte(i)})
+		}
+		accounts[string(address)] = acc
+		trie.put(address, rlpEncodeList(
+			rlpEncodeUint(acc.nonce),
+			rlpEncodeUint(acc.balance),
+			rlpEncodeBytes(acc.storageRoot),
+			rlpEncodeBytes(acc.codeHash),
+		))
+	}
+	return trie, accounts
+}
+
+func hexProof(proof [][]byte) []string {
+	nodes := make([]string, len(proof))
+	for i, node := range proof {
+		nodes[i] = "0x" + hex.EncodeToString(node)
+	}
+	return nodes
+}
+
+func TestVerifyAccount(t *testing.T) {
+	trie, accounts := testStateTrie(t)
+	for address, want := range accounts {
+		root, proof := trie.prove([]byte(address))
+		got, err := verifyAccount(root, []byte(address), hexProof(proof))
+		if err != nil {
+			t.Fatalf("account %x: %v", address, err)
+		}
+		if got.nonce.Cmp(want.nonce) != 0 || got.balance.Cmp(want.balance) != 0 ||
+			!bytes.Equal(got.storageRoot, want.storageRoot) || !bytes.Equal(got.codeHash, want.codeHash) {
+			t.Errorf("account %x = %+v, want %+v", address, got, want)
+		}
+	}
+}
+
+func TestVerifyAccountAbsent(t *testing.T) {
+	trie, _ := testStateTrie(t)
+	address := mustHex(t, "0x00000000000000000000000000000000deadbeef")
+	root, proof := trie.prove(address)
+	got, err := verifyAccount(root, address, hexProof(proof))
+	if err != nil {
+		t.Fatalf("proof of absence: %v", err)
+	}
+	if got.nonce.Sign() != 0 || got.balance.Sign() != 0 || !bytes.Equal(got.codeHash, emptyCodeHash) {
+		t.Errorf("absent account = %+v, want empty account", got)
+	}
+}
+
+func TestVerifyProofInvalid(t *testing.T) {
+	trie, accounts := testStateTrie(t)
+	var address []byte
+	for a := range accounts {
+		address = []byte(a)
+		break
+	}
+	root, proof := trie.prove(address)
+	if len(proof) < 2 {
+		t.Fatalf("proof has %d nodes, need at least 2", len(proof))
+	}
+	tamper := func(i, offset int) [][]byte {
+		tampered := make([][]byte, len(proof))
+		copy(tampered, proof)
+		tampered[i] = append([]byte{}, proof[i]...)
+		tampered[i][len(tampered[i])-offset] ^= 0x01
+		return tampered
+	}
+	otherRoot, _ := newTestTrie(true).prove(nil)
+
+	tests := []struct {
+		name  string
+		root  []byte
+		key   []byte
+		proof [][]byte
+		err   error
+	}{
+		{"truncated", root, address, proof[:len(proof)-1], errProofIncomplete},
+		{"empty", root, address, nil, errProofIncomplete},
+		{"tampered root node", root, address, tamper(0, 1), errProofHash},
+		{"tampered leaf node", root, address, tamper(len(proof)-1, 1), errProofHash},
+		{"wrong root", keccak256([]byte("root")), address, proof, errProofHash},
+		{"reordered", root, address, append([][]byte{proof[1], proof[0]}, proof[2:]...), errProofHash},
+		{"empty trie with nodes", otherRoot, address, proof, errProofHash},
+		{"invalid node", keccak256([]byte{0x01}), address, [][]byte{{0x01}}, errProofNode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := verifyProof(tt.root, tt.key, tt.proof)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("verifyProof() = %x, %v, want error %v", value, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyProofEmptyTrie(t *testing.T) {
+	value, err := verifyProof(emptyTrieRoot, []byte("key"), nil)
+	if err != nil || value != nil {
+		t.Errorf("verifyProof() = %x, %v, want nil, nil", value, err)
+	}
+}
+
+// Storage tries have small values, which are embedded in their parent node
+func TestVerifyStorageProof(t *testing.T) {
+	trie := newTestTrie(true)
+	values := make(map[string][]byte)
+	for i := 0; i < 20; i++ {
+		slot := leftPad([]byte{byte(i)}, 32)
+		value := rlpEncodeBytes(big.NewInt(int64(i*i + 1)).Bytes())
+		values[string(slot)] = value
+		trie.put(slot, value)
+	}
+	for slot, want := range values {
+		root, proof := trie.prove([]byte(slot))
+		got, err := verifyProof(root, []byte(slot), proof)
+		if err != nil {
+			t.Fatalf("slot %x: %v", slot, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("slot %x = %x, want %x", slot, got, want)
+		}
+	}
+}
+
+func TestDecodeCompactPath(t *testing.T) {
+	tests := []struct {
+		compact string
+		path    []byte
+		leaf    bool
+	}{
+		{"0x00010203", []byte{0, 1, 0, 2, 0, 3}, false},
+		{"0x1123", []byte{1, 2, 3}, false},
+		{"0x200f1cb8", []byte{0, 0xf, 1, 0xc, 0xb, 8}, true},
+		{"0x3f1cb8", []byte{0xf, 1, 0xc, 0xb, 8}, true},
+	}
+	for _, tt := range tests {
+		path, leaf, err := decodeCompactPath(mustHex(t, tt.compact))
+		if err != nil || !bytes.Equal(path, tt.path) || leaf != tt.leaf {
+			t.Errorf("decodeCompactPath(%s) = %v, %v, %v, want %v, %v", tt.compact, path, leaf, err, tt.path, tt.leaf)
+		}
+	}
+	if _, _, err := decodeCompactPath([]byte{0x40}); err == nil {
+		t.Error("decodeCompactPath() with invalid flag succeeded")
+	}
+}
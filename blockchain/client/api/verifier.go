@@ -0,0 +1,769 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// ---------------------------- //
+// Requester performs JSON-RPC requests to a blockchain network,
+// and REST requests to networks with the REST adapter
+// Implemented by Api and Verifier
+type Requester interface {
+	Request(network string, data []byte) ([]byte, int, error)
+	RestNetwork(path string) (string, string, bool)
+	RestRequest(network, method, path string, data []byte) ([]byte, int, error)
+}
+
+// ---------------------------- //
+// ProofConfig controls verification of Ethereum
+// state reads using Merkle-Patricia proofs
+type ProofConfig struct {
+	// Networks for which state reads are verified
+	Networks []string
+
+	// Trusted checkpoint block hash for each network
+	// If a network doesn't have a checkpoint, the trusted block
+	// header is the one agreed by a quorum of relay servers
+	// With a checkpoint, only state reads at the checkpoint block number
+	// are verified, and reads at block tags such as latest are rejected
+	Checkpoints map[string]string
+
+	// Number of relay servers that must agree on the latest
+	// block number and on the block header
+	// Minimum (and default) is 2
+	Quorum int
+
+	// Number of blocks behind the latest block used as trusted block
+	// Relay servers must agree on the latest block number within
+	// this number of blocks
+	Confirmations uint64
+
+	// How often the trusted block header is refreshed
+	Refresh time.Duration
+}
+
+// Verifier sits between the HTTP proxy and the Api,
+// verifying state reads for the configured networks
+// All other requests are passed through to the Api
+type Verifier struct {
+	api       *Api
+	config    ProofConfig
+	networks  map[string]struct{}
+	headers   map[string]*headerCache
+	logPrefix string
+	mux       sync.Mutex
+}
+
+// Trusted block header of a network
+// The mutex is held while the header is fetched, so that
+// concurrent requests to the network wait for a single fetch,
+// without blocking requests to other networks
+type headerCache struct {
+	mux    sync.Mutex
+	header *trustedHeader
+}
+
+// Block header trusted by the verifier
+type trustedHeader struct {
+	number    uint64
+	hash      []byte
+	stateRoot []byte
+	fetched   time.Time
+}
+
+// Methods verified with proofs
+var proofMethods = map[string]struct{}{
+	"eth_getBalance":          {},
+	"eth_getStorageAt":        {},
+	"eth_getCode":             {},
+	"eth_getTransactionCount": {},
+}
+
+// JSON-RPC error code for unverifiable responses
+const proofErrorCode = -32000
+
+// Metric names
+const (
+	MetricProofVerified = "proof_verified"
+	MetricProofRejected = "proof_rejected"
+)
+
+// ---------------------------- //
+// Create a new Verifier
+func NewVerifier(api *Api, config ProofConfig) *Verifier {
+	if config.Quorum < 2 {
+		config.Quorum = 2
+	}
+	if config.Refresh <= 0 {
+		config.Refresh = 30 * time.Second
+	}
+	networks := make(map[string]struct{}, len(config.Networks))
+	for _, network := range config.Networks {
+		networks[network] = struct{}{}
+	}
+	return &Verifier{
+		api:       api,
+		config:    config,
+		networks:  networks,
+		headers:   make(map[string]*headerCache),
+		logPrefix: api.logPrefix,
+	}
+}
+
+// ---------------------------- //
+// Do a Request to the given network
+// State reads are verified with proofs and answered with
+// the verified values, unverifiable reads get a JSON-RPC error
+// All other requests are passed through to the Api
+func (v *Verifier) Request(network string, data []byte) ([]byte, int, error) {
+	network = v.api.ResolveNetwork(network)
+	if _, ok := v.networks[network]; !ok {
+		return v.api.Request(network, data)
+	}
+
+	data = bytes.TrimSpace(data)
+	// Single request
+	if len(data) == 0 || data[0] != '[' {
+		call, verify, rpcErr := parseProofCall(data)
+		if !verify {
+			return v.api.Request(network, data)
+		}
+		resp, err := json.Marshal(v.proofResponse(network, call, rpcErr))
+		if err != nil {
+			return nil, 500, err
+		}
+		return resp, 200, nil
+	}
+
+	// Batch request
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		resp, _ := json.Marshal(errorResponse(nil, &jsonRpcError{Code: -32700, Message: "parse error"}))
+		return resp, 200, nil
+	}
+	responses := make([]json.RawMessage, 0, len(batch))
+	others := make([]json.RawMessage, 0, len(batch))
+	for _, item := range batch {
+		call, verify, rpcErr := parseProofCall(item)
+		if !verify {
+			others = append(others, item)
+			continue
+		}
+		resp, err := json.Marshal(v.proofResponse(network, call, rpcErr))
+		if err != nil {
+			return nil, 500, err
+		}
+		responses = append(responses, resp)
+	}
+	// Nothing to verify
+	if len(others) == len(batch) {
+		return v.api.Request(network, data)
+	}
+	// Send the remaining requests in a single batch
+	if len(others) > 0 {
+		othersData, err := json.Marshal(others)
+		if err != nil {
+			return nil, 500, err
+		}
+		resp, code, err := v.api.Request(network, othersData)
+		if err != nil {
+			return nil, code, err
+		}
+		var othersResp []json.RawMessage
+		if err := json.Unmarshal(resp, &othersResp); err != nil {
+			return nil, 500, fmt.Errorf("invalid batch response: %v", err)
+		}
+		responses = append(responses, othersResp...)
+	}
+	resp, err := json.Marshal(responses)
+	if err != nil {
+		return nil, 500, err
+	}
+	return resp, 200, nil
+}
+
+// ---------------------------- //
+// REST requests are passed through to the Api,
+// since state proofs only apply to JSON-RPC networks
+func (v *Verifier) RestNetwork(path string) (string, string, bool) {
+	return v.api.RestNetwork(path)
+}
+
+func (v *Verifier) RestRequest(network, method, path string, data []byte) ([]byte, int, error) {
+	return v.api.RestRequest(network, method, path, data)
+}
+
+// ---------------------------- //
+// Internal functions
+// ---------------------------- //
+
+// JSON-RPC request and response
+type jsonRpcCall struct {
+	Jsonrpc string            `json:"jsonrpc"`
+	Id      json.RawMessage   `json:"id,omitempty"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type jsonRpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonRpcResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonRpcError   `json:"error,omitempty"`
+}
+
+func isProofMethod(method string) bool {
+	_, ok := proofMethods[method]
+	return ok
+}
+
+// Parse a request and check if it must be verified
+// Requests are only passed through if their method can be
+// read and isn't verified with proofs, any other request
+// is verified, or rejected with the returned error
+// if it can't be parsed
+func parseProofCall(data []byte) (jsonRpcCall, bool, *jsonRpcError) {
+	var call jsonRpcCall
+	if err := json.Unmarshal(data, &call); err == nil {
+		return call, isProofMethod(call.Method), nil
+	}
+	var request struct {
+		Id     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return call, true, &jsonRpcError{Code: -32600, Message: "invalid request"}
+	}
+	if !isProofMethod(request.Method) {
+		return call, false, nil
+	}
+	call.Id = request.Id
+	return call, true, &jsonRpcError{Code: -32602, Message: "invalid params"}
+}
+
+// Build a JSON-RPC error response
+func errorResponse(id json.RawMessage, rpcErr *jsonRpcError) jsonRpcResponse {
+	return jsonRpcResponse{Jsonrpc: "2.0", Id: id, Error: rpcErr}
+}
+
+// Build the response of a state read, verifying it,
+// or rejecting it with the error of its parsing
+func (v *Verifier) proofResponse(network string, call jsonRpcCall, rpcErr *jsonRpcError) jsonRpcResponse {
+	if rpcErr == nil {
+		return v.verifiedCall(network, call)
+	}
+	jww.WARN.Printf("[%s] Rejecting unverifiable request on %s: %s", v.logPrefix, network, rpcErr.Message)
+	v.api.metrics.Inc(MetricProofRejected)
+	return errorResponse(call.Id, rpcErr)
+}
+
+// Verify a single state read and build its response
+func (v *Verifier) verifiedCall(network string, call jsonRpcCall) jsonRpcResponse {
+	response := jsonRpcResponse{Jsonrpc: "2.0", Id: call.Id}
+	result, err := v.verifyCall(network, call)
+	if err != nil {
+		jww.WARN.Printf("[%s] Rejecting unverifiable %s response on %s: %v", v.logPrefix, call.Method, network, err)
+		v.api.metrics.Inc(MetricProofRejected)
+		response.Error = &jsonRpcError{
+			Code:    proofErrorCode,
+			Message: fmt.Sprintf("response could not be verified: %v", err),
+		}
+		return response
+	}
+	v.api.metrics.Inc(MetricProofVerified)
+	response.Result, _ = json.Marshal(result)
+	return response
+}
+
+// Verify a single state read and return its result
+func (v *Verifier) verifyCall(network string, call jsonRpcCall) (string, error) {
+	// Parse parameters: address, [storage slot], block
+	var address, slot string
+	slots := []string{}
+	blockIdx := 1
+	if len(call.Params) < 1 || json.Unmarshal(call.Params[0], &address) != nil {
+		return "", errors.New("invalid address parameter")
+	}
+	if call.Method == "eth_getStorageAt" {
+		if len(call.Params) < 2 || json.Unmarshal(call.Params[1], &slot) != nil {
+			return "", errors.New("invalid storage slot parameter")
+		}
+		slots = []string{slot}
+		blockIdx = 2
+	}
+	var block json.RawMessage
+	if len(call.Params) > blockIdx {
+		block = call.Params[blockIdx]
+	}
+	number, err := parseBlockParam(block)
+	if err != nil {
+		return "", err
+	}
+
+	// Get trusted header
+	header, err := v.trustedHeader(network, number)
+	if err != nil {
+		return "", fmt.Errorf("no trusted block header: %v", err)
+	}
+	blockNumber := fmt.Sprintf("0x%x", header.number)
+
+	// Get proof
+	var proof accountProof
+	if err := v.call(network, "eth_getProof", &proof, address, slots, blockNumber); err != nil {
+		return "", err
+	}
+
+	// Verify account
+	addressBytes, err := decodeHex(address)
+	if err != nil || len(addressBytes) != 20 {
+		return "", errors.New("invalid address parameter")
+	}
+	account, err := verifyAccount(header.stateRoot, addressBytes, proof.AccountProof)
+	if err != nil {
+		return "", fmt.Errorf("invalid account proof: %v", err)
+	}
+
+	switch call.Method {
+	case "eth_getBalance":
+		return encodeQuantity(account.balance), nil
+	case "eth_getTransactionCount":
+		return encodeQuantity(account.nonce), nil
+	case "eth_getStorageAt":
+		if len(proof.StorageProof) != 1 {
+			return "", errors.New("missing storage proof")
+		}
+		slotBytes, err := decodeHex(slot)
+		if err != nil || len(slotBytes) > 32 {
+			return "", errors.New("invalid storage slot parameter")
+		}
+		value, err := verifyProof(account.storageRoot, leftPad(slotBytes, 32), decodeProof(proof.StorageProof[0].Proof))
+		if err != nil {
+			return "", fmt.Errorf("invalid storage proof: %v", err)
+		}
+		if value != nil {
+			item, err := rlpDecode(value)
+			if err != nil || item.list || len(item.data) > 32 {
+				return "", errors.New("invalid storage value")
+			}
+			value = item.data
+		}
+		return "0x" + hex.EncodeToString(leftPad(value, 32)), nil
+	case "eth_getCode":
+		if bytes.Equal(account.codeHash, emptyCodeHash) {
+			return "0x", nil
+		}
+		var code string
+		if err := v.call(network, "eth_getCode", &code, address, blockNumber); err != nil {
+			return "", err
+		}
+		codeBytes, err := decodeHex(code)
+		if err != nil {
+			return "", fmt.Errorf("invalid code: %v", err)
+		}
+		if !bytes.Equal(keccak256(codeBytes), account.codeHash) {
+			return "", errors.New("code hash mismatch")
+		}
+		return code, nil
+	}
+	return "", fmt.Errorf("method %s can't be verified", call.Method)
+}
+
+// Return the trusted block header for the network
+// If number is nil the latest trusted header is used
+func (v *Verifier) trustedHeader(network string, number *uint64) (*trustedHeader, error) {
+	checkpoint := v.config.Checkpoints[network]
+
+	// Quorum mode, for a specific block
+	if checkpoint == "" && number != nil {
+		return v.fetchHeader(network, "eth_getBlockByNumber", fmt.Sprintf("0x%x", *number), v.config.Quorum)
+	}
+
+	v.mux.Lock()
+	cache, ok := v.headers[network]
+	if !ok {
+		cache = &headerCache{}
+		v.headers[network] = cache
+	}
+	v.mux.Unlock()
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+
+	// Checkpoint mode
+	// Only the checkpoint block can be verified, since block tags
+	// would be answered with state of the checkpoint block
+	if checkpoint != "" {
+		if number == nil {
+			return nil, errors.New("block tags can't be verified with a checkpoint, use the checkpoint block number")
+		}
+		if cache.header == nil {
+			hash, err := decodeHex(checkpoint)
+			if err != nil || len(hash) != 32 {
+				return nil, fmt.Errorf("invalid checkpoint %s", checkpoint)
+			}
+			header, err := v.fetchHeader(network, "eth_getBlockByHash", checkpoint, 0)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(header.hash, hash) {
+				return nil, errors.New("checkpoint block hash mismatch")
+			}
+			cache.header = header
+		}
+		if *number != cache.header.number {
+			return nil, fmt.Errorf("only checkpoint block %d can be verified", cache.header.number)
+		}
+		return cache.header, nil
+	}
+
+	// Quorum mode, for the latest block
+	if cache.header != nil && time.Since(cache.header.fetched) < v.config.Refresh {
+		return cache.header, nil
+	}
+	latest, err := v.latestBlock(network)
+	if err != nil {
+		return nil, err
+	}
+	trusted := latest
+	if trusted > v.config.Confirmations {
+		trusted -= v.config.Confirmations
+	}
+	header, err := v.fetchHeader(network, "eth_getBlockByNumber", fmt.Sprintf("0x%x", trusted), v.config.Quorum)
+	if err != nil {
+		return nil, err
+	}
+	jww.DEBUG.Printf("[%s] Trusted block header for %s: %d", v.logPrefix, network, header.number)
+	cache.header = header
+	return header, nil
+}
+
+// Get the latest block number, agreed by a quorum of relay servers
+// within the number of confirmations, since relay servers
+// can be a few blocks apart
+func (v *Verifier) latestBlock(network string) (uint64, error) {
+	data, err := json.Marshal(jsonRpcCall{
+		Jsonrpc: "2.0",
+		Id:      json.RawMessage("1"),
+		Method:  "eth_blockNumber",
+		Params:  []json.RawMessage{},
+	})
+	if err != nil {
+		return 0, err
+	}
+	resp, _, err := v.api.requestWith(network, data, requestOptions{
+		quorum: v.config.Quorum,
+		agree: func(a, b []byte) bool {
+			first, errA := parseBlockNumber(a)
+			second, errB := parseBlockNumber(b)
+			if errA != nil || errB != nil {
+				return false
+			}
+			if first < second {
+				return second-first <= v.config.Confirmations
+			}
+			return first-second <= v.config.Confirmations
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return parseBlockNumber(resp)
+}
+
+// Parse an eth_blockNumber response
+func parseBlockNumber(resp []byte) (uint64, error) {
+	var latest string
+	if err := parseResult(resp, &latest); err != nil {
+		return 0, err
+	}
+	number, err := parseQuantity(latest)
+	if err != nil || !number.IsUint64() {
+		return 0, fmt.Errorf("invalid block number %s", latest)
+	}
+	return number.Uint64(), nil
+}
+
+// Fetch a block header, with the given quorum, and verify its hash
+func (v *Verifier) fetchHeader(network, method, block string, quorum int) (*trustedHeader, error) {
+	data, err := json.Marshal(jsonRpcCall{
+		Jsonrpc: "2.0",
+		Id:      json.RawMessage("1"),
+		Method:  method,
+		Params:  []json.RawMessage{json.RawMessage(fmt.Sprintf("%q", block)), json.RawMessage("false")},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var header blockHeader
+	resp, _, err := v.api.requestWith(network, data, requestOptions{quorum: quorum})
+	if err != nil {
+		return nil, err
+	}
+	if err := parseResult(resp, &header); err != nil {
+		return nil, err
+	}
+	return header.verify()
+}
+
+// Do a JSON-RPC call through the Api and parse its result
+func (v *Verifier) call(network, method string, result interface{}, params ...interface{}) error {
+	return callJsonRpc(v.api, network, method, result, params...)
+}
+
+// Parse the result of a JSON-RPC response
+func parseResult(resp []byte, result interface{}) error {
+	var response jsonRpcResponse
+	if err := json.Unmarshal(resp, &response); err != nil {
+		return fmt.Errorf("invalid JSON-RPC response: %v", err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("JSON-RPC error %d: %s", response.Error.Code, response.Error.Message)
+	}
+	if len(response.Result) == 0 || string(response.Result) == "null" {
+		return errors.New("empty JSON-RPC result")
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// Parse a block parameter
+// Returns nil for block tags, which use the latest trusted block
+func parseBlockParam(block json.RawMessage) (*uint64, error) {
+	if len(block) == 0 {
+		return nil, nil
+	}
+	var tag string
+	if err := json.Unmarshal(block, &tag); err != nil {
+		return nil, errors.New("only block numbers and tags can be verified")
+	}
+	switch tag {
+	case "latest", "safe", "finalized", "pending":
+		return nil, nil
+	}
+	number, err := parseQuantity(tag)
+	if err != nil || !number.IsUint64() {
+		return nil, fmt.Errorf("invalid block parameter %s", tag)
+	}
+	n := number.Uint64()
+	return &n, nil
+}
+
+// ---------------------------- //
+// Account proofs
+// ---------------------------- //
+
+// Result of eth_getProof
+type accountProof struct {
+	AccountProof []string `json:"accountProof"`
+	StorageProof []struct {
+		Proof []string `json:"proof"`
+	} `json:"storageProof"`
+}
+
+// Account state stored in the state trie
+type account struct {
+	nonce       *big.Int
+	balance     *big.Int
+	storageRoot []byte
+	codeHash    []byte
+}
+
+// Verify the account proof and return the account state
+// A proof of absence returns an empty account
+func verifyAccount(stateRoot, address []byte, proof []string) (*account, error) {
+	value, err := verifyProof(stateRoot, address, decodeProof(proof))
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return &account{
+			nonce:       new(big.Int),
+			balance:     new(big.Int),
+			storageRoot: emptyTrieRoot,
+			codeHash:    emptyCodeHash,
+		}, nil
+	}
+	item, err := rlpDecode(value)
+	if err != nil {
+		return nil, err
+	}
+	if !item.list || len(item.items) != 4 {
+		return nil, errors.New("invalid account encoding")
+	}
+	return &account{
+		nonce:       new(big.Int).SetBytes(item.items[0].data),
+		balance:     new(big.Int).SetBytes(item.items[1].data),
+		storageRoot: item.items[2].data,
+		codeHash:    item.items[3].data,
+	}, nil
+}
+
+// Decode proof nodes from hex
+// Invalid nodes are left empty and fail verification
+func decodeProof(proof []string) [][]byte {
+	nodes := make([][]byte, len(proof))
+	for i, node := range proof {
+		nodes[i], _ = decodeHex(node)
+	}
+	return nodes
+}
+
+// ---------------------------- //
+// Block headers
+// ---------------------------- //
+
+// Block header fields as returned by eth_getBlockBy*
+// Optional fields were added by later forks
+type blockHeader struct {
+	Hash                  string  `json:"hash"`
+	ParentHash            string  `json:"parentHash"`
+	Sha3Uncles            string  `json:"sha3Uncles"`
+	Miner                 string  `json:"miner"`
+	StateRoot             string  `json:"stateRoot"`
+	TransactionsRoot      string  `json:"transactionsRoot"`
+	ReceiptsRoot          string  `json:"receiptsRoot"`
+	LogsBloom             string  `json:"logsBloom"`
+	Difficulty            string  `json:"difficulty"`
+	Number                string  `json:"number"`
+	GasLimit              string  `json:"gasLimit"`
+	GasUsed               string  `json:"gasUsed"`
+	Timestamp             string  `json:"timestamp"`
+	ExtraData             string  `json:"extraData"`
+	MixHash               string  `json:"mixHash"`
+	Nonce                 string  `json:"nonce"`
+	BaseFeePerGas         *string `json:"baseFeePerGas"`
+	WithdrawalsRoot       *string `json:"withdrawalsRoot"`
+	BlobGasUsed           *string `json:"blobGasUsed"`
+	ExcessBlobGas         *string `json:"excessBlobGas"`
+	ParentBeaconBlockRoot *string `json:"parentBeaconBlockRoot"`
+	RequestsHash          *string `json:"requestsHash"`
+}
+
+// Compute the header hash from its fields and check
+// it matches the returned hash
+func (h *blockHeader) verify() (*trustedHeader, error) {
+	fields := make([][]byte, 0, 21)
+	var err error
+	addBytes := func(value string) {
+		if err == nil {
+			var b []byte
+			if b, err = decodeHex(value); err == nil {
+				fields = append(fields, rlpEncodeBytes(b))
+			}
+		}
+	}
+	addQuantity := func(value string) {
+		if err == nil {
+			var q *big.Int
+			if q, err = parseQuantity(value); err == nil {
+				fields = append(fields, rlpEncodeUint(q))
+			}
+		}
+	}
+	addBytes(h.ParentHash)
+	addBytes(h.Sha3Uncles)
+	addBytes(h.Miner)
+	addBytes(h.StateRoot)
+	addBytes(h.TransactionsRoot)
+	addBytes(h.ReceiptsRoot)
+	addBytes(h.LogsBloom)
+	addQuantity(h.Difficulty)
+	addQuantity(h.Number)
+	addQuantity(h.GasLimit)
+	addQuantity(h.GasUsed)
+	addQuantity(h.Timestamp)
+	addBytes(h.ExtraData)
+	addBytes(h.MixHash)
+	addBytes(h.Nonce)
+	// Fork fields are appended in order, while present
+	optional := []struct {
+		value    *string
+		quantity bool
+	}{
+		{h.BaseFeePerGas, true},
+		{h.WithdrawalsRoot, false},
+		{h.BlobGasUsed, true},
+		{h.ExcessBlobGas, true},
+		{h.ParentBeaconBlockRoot, false},
+		{h.RequestsHash, false},
+	}
+	for _, field := range optional {
+		if field.value == nil {
+			break
+		}
+		if field.quantity {
+			addQuantity(*field.value)
+		} else {
+			addBytes(*field.value)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid block header: %v", err)
+	}
+
+	hash, err := decodeHex(h.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block hash: %v", err)
+	}
+	if !bytes.Equal(keccak256(rlpEncodeList(fields...)), hash) {
+		return nil, errors.New("block header hash mismatch")
+	}
+	number, _ := parseQuantity(h.Number)
+	stateRoot, _ := decodeHex(h.StateRoot)
+	return &trustedHeader{
+		number:    number.Uint64(),
+		hash:      hash,
+		stateRoot: stateRoot,
+		fetched:   time.Now(),
+	}, nil
+}
+
+// ---------------------------- //
+// Hex encoding
+// ---------------------------- //
+
+// Decode 0x prefixed hex data
+func decodeHex(value string) ([]byte, error) {
+	value = strings.TrimPrefix(value, "0x")
+	if len(value)%2 == 1 {
+		value = "0" + value
+	}
+	return hex.DecodeString(value)
+}
+
+// Parse a 0x prefixed hex quantity
+func parseQuantity(value string) (*big.Int, error) {
+	q, ok := new(big.Int).SetString(strings.TrimPrefix(value, "0x"), 16)
+	if !ok || !strings.HasPrefix(value, "0x") {
+		return nil, fmt.Errorf("invalid quantity %s", value)
+	}
+	return q, nil
+}
+
+// Encode a quantity as 0x prefixed hex
+func encodeQuantity(value *big.Int) string {
+	return "0x" + value.Text(16)
+}
+
+// Left pad data with zeros to the given size
+func leftPad(data []byte, size int) []byte {
+	if len(data) >= size {
+		return data
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(data):], data)
+	return padded
+}
@@ -0,0 +1,255 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Ethereum mainnet block headers, as returned by eth_getBlockByNumber
+var (
+	// Genesis block
+	mainnetBlock0 = `{
+		"hash": "0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3",
+		"parentHash": "0x0000000000000000000000000000000000000000000000000000000000000000",
+		"sha3Uncles": "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347",
+		"miner": "0x0000000000000000000000000000000000000000",
+		"stateRoot": "0xd7f8974fb5ac78d9ac099b9ad5018bedc2ce0a72dad1827a1709da30580f0544",
+		"transactionsRoot": "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+		"receiptsRoot": "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+		"logsBloom": "0x` + zeroBloom + `",
+		"difficulty": "0x400000000",
+		"number": "0x0",
+		"gasLimit": "0x1388",
+		"gasUsed": "0x0",
+		"timestamp": "0x0",
+		"extraData": "0x11bbe8db4e347b4e8c937c1c8370e4b5ed33adb3db69cbdb7a38e1e50b1b82fa",
+		"mixHash": "0x0000000000000000000000000000000000000000000000000000000000000000",
+		"nonce": "0x0000000000000042"
+	}`
+
+	// First mined block
+	mainnetBlock1 = `{
+		"hash": "0x88e96d4537bea4d9c05d12549907b32561d3bf31f45aae734cdc119f13406cb6",
+		"parentHash": "0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3",
+		"sha3Uncles": "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347",
+		"miner": "0x05a56e2d52c817161883f50c441c3228cfe54d9f",
+		"stateRoot": "0xd67e4d450343046425ae4271474353857ab860dbc0a1dde64b41b5cd3a532bf3",
+		"transactionsRoot": "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+		"receiptsRoot": "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+		"logsBloom": "0x` + zeroBloom + `",
+		"difficulty": "0x3ff800000",
+		"number": "0x1",
+		"gasLimit": "0x1388",
+		"gasUsed": "0x0",
+		"timestamp": "0x55ba4224",
+		"extraData": "0x476574682f76312e302e302f6c696e75782f676f312e342e32",
+		"mixHash": "0x969b900de27b6ac6a67742365dd65f55a0526c41fd18e1b16f1a1215c2e66f59",
+		"nonce": "0x539bd4979fef1ec4"
+	}`
+)
+
+var zeroBloom = strings.Repeat("00", 256)
+
+func parseHeader(t *testing.T, data string) *blockHeader {
+	t.Helper()
+	var header blockHeader
+	if err := json.Unmarshal([]byte(data), &header); err != nil {
+		t.Fatalf("invalid header fixture: %v", err)
+	}
+	return &header
+}
+
+func TestBlockHeaderVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		number uint64
+	}{
+		{"genesis", mainnetBlock0, 0},
+		{"block 1", mainnetBlock1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := parseHeader(t, tt.header)
+			trusted, err := header.verify()
+			if err != nil {
+				t.Fatalf("verify() error: %v", err)
+			}
+			if trusted.number != tt.number || string(trusted.stateRoot) != string(mustHex(t, header.StateRoot)) {
+				t.Errorf("verify() = block %d state root %x, want block %d state root %s",
+					trusted.number, trusted.stateRoot, tt.number, header.StateRoot)
+			}
+		})
+	}
+}
+
+func TestBlockHeaderVerifyTampered(t *testing.T) {
+	tamper := []struct {
+		name   string
+		modify func(h *blockHeader)
+	}{
+		{"state root", func(h *blockHeader) {
+			h.StateRoot = "0xd7f8974fb5ac78d9ac099b9ad5018bedc2ce0a72dad1827a1709da30580f0545"
+		}},
+		{"number", func(h *blockHeader) { h.Number = "0x2" }},
+		{"hash", func(h *blockHeader) {
+			h.Hash = "0x88e96d4537bea4d9c05d12549907b32561d3bf31f45aae734cdc119f13406cb7"
+		}},
+		{"extra field", func(h *blockHeader) {
+			baseFee := "0x3b9aca00"
+			h.BaseFeePerGas = &baseFee
+		}},
+		{"invalid quantity", func(h *blockHeader) { h.GasLimit = "1388" }},
+		{"invalid bytes", func(h *blockHeader) { h.Miner = "0xzz" }},
+	}
+	for _, tt := range tamper {
+		t.Run(tt.name, func(t *testing.T) {
+			header := parseHeader(t, mainnetBlock1)
+			tt.modify(header)
+			if _, err := header.verify(); err == nil {
+				t.Error("verify() of tampered header succeeded")
+			}
+		})
+	}
+}
+
+func TestParseProofCall(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		verify bool
+		code   int
+	}{
+		{"proof method", `{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0x00000000000000000000000000000000deadbeef","latest"]}`, true, 0},
+		{"other method", `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`, false, 0},
+		{"other method with object params", `{"jsonrpc":"2.0","id":1,"method":"eth_foo","params":{"a":1}}`, false, 0},
+		{"proof method with object params", `{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":{"address":"0x00"}}`, true, -32602},
+		{"proof method with string params", `{"jsonrpc":"2.0","id":1,"method":"eth_getStorageAt","params":"0x00"}`, true, -32602},
+		{"method not a string", `{"jsonrpc":"2.0","id":1,"method":1,"params":[]}`, true, -32600},
+		{"not an object", `"eth_getBalance"`, true, -32600},
+		{"invalid JSON", `{"method":"eth_getBalance"`, true, -32600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, verify, rpcErr := parseProofCall([]byte(tt.data))
+			code := 0
+			if rpcErr != nil {
+				code = rpcErr.Code
+			}
+			if verify != tt.verify || code != tt.code {
+				t.Errorf("parseProofCall() = %v, %d, want %v, %d", verify, code, tt.verify, tt.code)
+			}
+		})
+	}
+}
+
+// Malformed state reads are rejected, instead of being passed through
+func TestVerifierRejectsMalformed(t *testing.T) {
+	network := "/ethereum/mainnet"
+	a := &Api{
+		metrics:  &Metrics{},
+		relayers: make(map[string]*Relay),
+		active:   make(map[string]bool),
+		stopChan: make(chan struct{}),
+	}
+	v := NewVerifier(a, ProofConfig{Networks: []string{network}})
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"single", `{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":{"address":"0x00"}}`},
+		{"batch", `[{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionCount","params":"0x00"}]`},
+		{"batch with malformed entry", `[{"jsonrpc":"2.0","id":1,"method":"eth_getCode","params":"0x00"},{"id":2,"method":3}]`},
+		{"invalid batch", `[{"jsonrpc":"2.0","id":1,"method":"eth_getBalance"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, code, err := v.Request(network, []byte(tt.data))
+			if err != nil || code != 200 {
+				t.Fatalf("Request() = %d, %v", code, err)
+			}
+			var responses []jsonRpcResponse
+			if strings.HasPrefix(string(resp), "[") {
+				err = json.Unmarshal(resp, &responses)
+			} else {
+				responses = make([]jsonRpcResponse, 1)
+				err = json.Unmarshal(resp, &responses[0])
+			}
+			if err != nil {
+				t.Fatalf("invalid response %s: %v", resp, err)
+			}
+			for _, response := range responses {
+				if response.Error == nil {
+					t.Errorf("response %s isn't an error", resp)
+				}
+			}
+		})
+	}
+	if rejected := a.metrics.Get(MetricProofRejected); rejected != 4 {
+		t.Errorf("%s = %d, want 4", MetricProofRejected, rejected)
+	}
+}
+
+// State reads at block tags are rejected in checkpoint mode,
+// since they would be answered with the checkpoint block state
+func TestVerifierCheckpointRejectsTags(t *testing.T) {
+	network := "/ethereum/mainnet"
+	a := &Api{
+		metrics:  &Metrics{},
+		relayers: make(map[string]*Relay),
+		active:   make(map[string]bool),
+		stopChan: make(chan struct{}),
+	}
+	v := NewVerifier(a, ProofConfig{
+		Networks:    []string{network},
+		Checkpoints: map[string]string{network: "0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"},
+	})
+	for _, block := range []string{`"latest"`, `"safe"`, `"finalized"`, `"pending"`, ``} {
+		params := `"0x00000000000000000000000000000000deadbeef"`
+		if block != "" {
+			params += "," + block
+		}
+		data := `{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":[` + params + `]}`
+		resp, _, err := v.Request(network, []byte(data))
+		if err != nil {
+			t.Fatalf("Request() error: %v", err)
+		}
+		var response jsonRpcResponse
+		if err := json.Unmarshal(resp, &response); err != nil || response.Error == nil ||
+			!strings.Contains(response.Error.Message, "checkpoint") {
+			t.Errorf("block %s: response %s, want checkpoint error", block, resp)
+		}
+	}
+}
+
+func TestParseBlockParam(t *testing.T) {
+	tests := []struct {
+		param  string
+		number int64
+		err    bool
+	}{
+		{``, -1, false},
+		{`"latest"`, -1, false},
+		{`"finalized"`, -1, false},
+		{`"0x10"`, 16, false},
+		{`"16"`, 0, true},
+		{`{"blockHash":"0x00"}`, 0, true},
+		{`"0x10000000000000000"`, 0, true},
+	}
+	for _, tt := range tests {
+		number, err := parseBlockParam(json.RawMessage(tt.param))
+		switch {
+		case tt.err:
+			if err == nil {
+				t.Errorf("parseBlockParam(%s) succeeded, want error", tt.param)
+			}
+		case err != nil:
+			t.Errorf("parseBlockParam(%s) error: %v", tt.param, err)
+		case tt.number < 0 && number != nil:
+			t.Errorf("parseBlockParam(%s) = %d, want nil", tt.param, *number)
+		case tt.number >= 0 && (number == nil || *number != uint64(tt.number)):
+			t.Errorf("parseBlockParam(%s) = %v, want %d", tt.param, number, tt.number)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// ---------------------------- //
+// VerifyConfig controls verification of read requests
+// by sending them to multiple relay servers
+// and requiring all responses to agree
+type VerifyConfig struct {
+	// Number of relay servers that must agree on the response
+	// Verification is disabled if this is less than 2
+	Quorum int
+
+	// Per method quorum, overriding the default quorum
+	// A value less than 2 disables verification for the method
+	Methods map[string]int
+}
+
+// Error returned when relay servers disagree on a response
+var ErrVerificationFailed = errors.New("relay servers disagree on response")
+
+// Return the quorum needed for the given request data
+// For batch requests, the highest quorum of all methods is used
+// Write requests are never verified
+func (v VerifyConfig) quorumFor(data []byte) int {
+	methods := cmix.ParseMethods(data)
+	if len(methods) == 0 || cmix.IsWriteRequest(data) {
+		return 0
+	}
+	quorum := 0
+	for _, method := range methods {
+		q, ok := v.Methods[method]
+		if !ok {
+			q = v.Quorum
+		}
+		if q > quorum {
+			quorum = q
+		}
+	}
+	if quorum < 2 {
+		return 0
+	}
+	return quorum
+}
+
+// ---------------------------- //
+// Send the request to quorum relay servers at once
+// Failed requests are sent to the remaining relay servers
+// Returns the response if all quorum responses agree,
+// ErrVerificationFailed if they don't, or the last error
+// if not enough relay servers responded
+func (a *Api) verifiedRequest(relayers []*Relay, request cmix.Request, opts requestOptions) ([]byte, int, error) {
+	quorum := opts.quorum
+	if len(relayers) < quorum {
+		a.metrics.Inc(MetricVerifyFailed)
+		return nil, 500, fmt.Errorf("%w: need %d relay servers to verify response, only %d available",
+			errNotEnoughRelayers, quorum, len(relayers))
+	}
+
+	results := make(chan relayResult, len(relayers))
+	launched := 0
+	launch := func() {
+		r := relayers[launched]
+		launched++
+		go func() {
+			resp, code, err := r.Request(request)
+			results <- relayResult{r, resp, code, err}
+		}()
+	}
+	for launched < quorum {
+		launch()
+	}
+
+	// Collect responses
+	responses := make([]relayResult, 0, quorum)
+	last := relayResult{code: 500, err: errors.New("no relay server responded")}
+	for received := 0; received < launched && len(responses) < quorum; received++ {
+		res := <-results
+		if res.err != nil {
+			last = res
+			if launched < len(relayers) {
+				launch()
+			}
+			continue
+		}
+		responses = append(responses, res)
+	}
+	if len(responses) < quorum {
+		jww.WARN.Printf("[%s] Only %d of %d relay servers responded, can't verify response",
+			a.logPrefix, len(responses), quorum)
+		a.metrics.Inc(MetricVerifyFailed)
+		return nil, last.code, last.err
+	}
+
+	// Compare responses
+	agree := opts.agree
+	if agree == nil {
+		agree = equalResponses
+	}
+	for _, res := range responses {
+		if _, err := normalizeResponse(res.resp); err != nil {
+			jww.WARN.Printf("[%s] Invalid response from relay server %s: %v", a.logPrefix, res.relay.name, err)
+		}
+	}
+	for _, res := range responses[1:] {
+		if res.code != responses[0].code || !agree(responses[0].resp, res.resp) {
+			jww.ERROR.Printf("[%s] Relay servers %s and %s disagree on response for %v",
+				a.logPrefix, responses[0].relay.name, res.relay.name, cmix.ParseMethods(request.Data))
+			a.metrics.Inc(MetricVerifyDisagreement)
+			return nil, 500, ErrVerificationFailed
+		}
+	}
+	a.metrics.Inc(MetricVerifyAgreed)
+	return responses[0].resp, responses[0].code, nil
+}
+
+// Check if two responses are equal once normalized
+func equalResponses(a, b []byte) bool {
+	expected, _ := normalizeResponse(a)
+	got, _ := normalizeResponse(b)
+	return bytes.Equal(expected, got)
+}
+
+var errNotEnoughRelayers = errors.New("not enough relay servers")
+
+// Normalize a JSON-RPC response for comparison
+// All relay servers get the same request, so ids are kept
+// and each response of a batch is compared with the response
+// with the same id of the other batch
+// Batch responses are sorted by id, since their order isn't guaranteed
+// Invalid JSON is returned unchanged, along with the error
+func normalizeResponse(data []byte) ([]byte, error) {
+	var parsed interface{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return data, err
+	}
+	batch, ok := parsed.([]interface{})
+	if !ok {
+		// Marshal sorts map keys, so the output is canonical
+		return json.Marshal(parsed)
+	}
+	type batchItem struct {
+		id   string
+		data json.RawMessage
+	}
+	items := make([]batchItem, len(batch))
+	for i, item := range batch {
+		var id interface{}
+		if obj, ok := item.(map[string]interface{}); ok {
+			id = obj["id"]
+		}
+		idData, err := json.Marshal(id)
+		if err != nil {
+			return data, err
+		}
+		itemData, err := json.Marshal(item)
+		if err != nil {
+			return data, err
+		}
+		items[i] = batchItem{string(idData), itemData}
+	}
+	// Responses without a distinct id keep their order
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].id < items[j].id
+	})
+	sorted := make([]json.RawMessage, len(items))
+	for i, item := range items {
+		sorted[i] = item.data
+	}
+	return json.Marshal(sorted)
+}
@@ -0,0 +1,50 @@
+package api
+
+import "testing"
+
+func TestEqualResponses(t *testing.T) {
+	honest := `[{"jsonrpc":"2.0","id":1,"result":"0x10"},{"jsonrpc":"2.0","id":2,"result":"0x20"}]`
+	tests := []struct {
+		name  string
+		other string
+		equal bool
+	}{
+		{"same", honest, true},
+		{"reformatted", `[ {"id":1, "result":"0x10", "jsonrpc":"2.0"}, {"jsonrpc":"2.0","result":"0x20","id":2} ]`, true},
+		{"reordered", `[{"jsonrpc":"2.0","id":2,"result":"0x20"},{"jsonrpc":"2.0","id":1,"result":"0x10"}]`, true},
+		// A relay server swapping results between batch entries
+		// must not agree with the honest relay servers
+		{"swapped results", `[{"jsonrpc":"2.0","id":1,"result":"0x20"},{"jsonrpc":"2.0","id":2,"result":"0x10"}]`, false},
+		{"swapped ids", `[{"jsonrpc":"2.0","id":2,"result":"0x10"},{"jsonrpc":"2.0","id":1,"result":"0x20"}]`, false},
+		{"missing ids", `[{"jsonrpc":"2.0","result":"0x10"},{"jsonrpc":"2.0","result":"0x20"}]`, false},
+		{"missing entry", `[{"jsonrpc":"2.0","id":1,"result":"0x10"}]`, false},
+		{"single", `{"jsonrpc":"2.0","id":1,"result":"0x10"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := equalResponses([]byte(honest), []byte(tt.other)); got != tt.equal {
+				t.Errorf("equalResponses() = %v, want %v", got, tt.equal)
+			}
+		})
+	}
+}
+
+func TestEqualResponsesSingle(t *testing.T) {
+	honest := `{"jsonrpc":"2.0","id":7,"result":"0x10"}`
+	tests := []struct {
+		name  string
+		other string
+		equal bool
+	}{
+		{"same", `{"result":"0x10","id":7,"jsonrpc":"2.0"}`, true},
+		{"different result", `{"jsonrpc":"2.0","id":7,"result":"0x11"}`, false},
+		{"different id", `{"jsonrpc":"2.0","id":8,"result":"0x10"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := equalResponses([]byte(honest), []byte(tt.other)); got != tt.equal {
+				t.Errorf("equalResponses() = %v, want %v", got, tt.equal)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// ---------------------------- //
+// WebSocket subscriptions
+//
+// The HTTP proxy accepts WebSocket connections on the network paths,
+// such as ws://localhost:9296/ethereum/mainnet, for dApps that use eth_subscribe
+// Requests received over the WebSocket are sent over cMix like HTTP requests
+// Since cMix requests are request/response, subscriptions are emulated by
+// polling filters installed on the network:
+//
+//	newHeads               eth_newBlockFilter, blocks fetched with eth_getBlockByHash
+//	logs                   eth_newFilter with the subscription options
+//	newPendingTransactions eth_newPendingTransactionFilter
+//
+// Filter changes are delivered to the WebSocket client as eth_subscription
+// notifications. Filters are kept on the relay server that created them
+// Filters that can't be polled, because they expired, their endpoint is
+// no longer used by the relay server or their relay server is no longer
+// active, are installed again, so changes in between can be missed
+
+// Default interval between polls of subscription filters
+const DefaultSubscriptionPoll = 4 * time.Second
+
+// Maximum time to write a message to a WebSocket connection
+const wsWriteTimeout = 10 * time.Second
+
+// Origins are checked before upgrading, by the browser origin policy
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// Filter installed for each subscription kind
+var subscriptionFilters = map[string]string{
+	"newHeads":               "eth_newBlockFilter",
+	"logs":                   "eth_newFilter",
+	"newPendingTransactions": "eth_newPendingTransactionFilter",
+}
+
+// WebSocket connection to a network
+type wsSession struct {
+	api       Requester
+	network   string
+	conn      *websocket.Conn
+	poll      time.Duration
+	logPrefix string
+	logMode   cmix.LogMode
+
+	writeMux sync.Mutex
+
+	mux           sync.Mutex
+	subscriptions map[string]*subscription
+
+	// Requests in progress and subscription pollers
+	requests sync.WaitGroup
+	pollers  sync.WaitGroup
+}
+
+// Subscription emulated with a filter
+type subscription struct {
+	id       string
+	kind     string
+	method   string
+	params   []interface{}
+	filterId string
+	stopChan chan struct{}
+}
+
+type subscriptionResult struct {
+	Subscription string          `json:"subscription"`
+	Result       json.RawMessage `json:"result"`
+}
+
+type subscriptionNotification struct {
+	Jsonrpc string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  subscriptionResult `json:"params"`
+}
+
+// ---------------------------- //
+// Upgrade the request to a WebSocket connection, and serve
+// requests and subscriptions on it until it is closed
+func (hp *HttpProxy) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		jww.WARN.Printf("[%s] WebSocket upgrade error: %v", hp.logPrefix, err)
+		return
+	}
+	network := r.URL.Path
+	if hp.network != "" {
+		network = hp.network
+	}
+	s := &wsSession{
+		api:           hp.api,
+		network:       network,
+		conn:          conn,
+		poll:          hp.config.SubscriptionPoll,
+		logPrefix:     hp.logPrefix,
+		logMode:       hp.logMode,
+		subscriptions: make(map[string]*subscription),
+	}
+	if !hp.addSession(s) {
+		conn.Close()
+		return
+	}
+	jww.INFO.Printf("[%s] WebSocket connection opened on %s", hp.logPrefix, s.network)
+	s.serve()
+	hp.removeSession(s)
+	jww.INFO.Printf("[%s] WebSocket connection closed on %s", hp.logPrefix, s.network)
+}
+
+// Read and handle messages until the connection is closed,
+// then stop subscriptions and uninstall their filters
+func (s *wsSession) serve() {
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		s.logMode.Log(s.logPrefix, "Got WebSocket request", data)
+		// Requests are handled in parallel, since each
+		// of them takes a cMix round trip
+		s.requests.Add(1)
+		go func() {
+			defer s.requests.Done()
+			resp := s.handle(data)
+			if err := s.write(resp); err == nil {
+				s.logMode.Log(s.logPrefix, "Response", resp)
+			}
+		}()
+	}
+	s.conn.Close()
+	s.requests.Wait()
+
+	s.mux.Lock()
+	subscriptions := s.subscriptions
+	s.subscriptions = make(map[string]*subscription)
+	s.mux.Unlock()
+	for _, sub := range subscriptions {
+		close(sub.stopChan)
+	}
+	s.pollers.Wait()
+}
+
+// Close the connection, which stops serving it
+func (s *wsSession) close() {
+	s.conn.Close()
+}
+
+// Write a message to the connection
+func (s *wsSession) write(data []byte) error {
+	s.writeMux.Lock()
+	defer s.writeMux.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return s.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// Handle a request, returning the response
+// Subscription requests are handled locally,
+// all others are sent to the network
+func (s *wsSession) handle(data []byte) []byte {
+	var call jsonRpcCall
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] != '[' && json.Unmarshal(trimmed, &call) == nil {
+		switch call.Method {
+		case "eth_subscribe":
+			result, rpcErr := s.subscribe(call.Params)
+			return s.response(call.Id, result, rpcErr)
+		case "eth_unsubscribe":
+			result, rpcErr := s.unsubscribe(call.Params)
+			return s.response(call.Id, result, rpcErr)
+		}
+	}
+	resp, _, err := s.api.Request(s.network, data)
+	if err != nil {
+		jww.ERROR.Printf("[%s] Request returned an error: %v", s.logPrefix, err)
+		return s.response(call.Id, nil, &jsonRpcError{Code: -32603, Message: err.Error()})
+	}
+	return resp
+}
+
+// Build a JSON-RPC response with a result or an error
+func (s *wsSession) response(id json.RawMessage, result interface{}, rpcErr *jsonRpcError) []byte {
+	response := jsonRpcResponse{Jsonrpc: "2.0", Id: id, Error: rpcErr}
+	if rpcErr == nil {
+		response.Result, _ = json.Marshal(result)
+	}
+	data, _ := json.Marshal(response)
+	return data
+}
+
+// ---------------------------- //
+// Subscriptions
+// ---------------------------- //
+
+// Create a subscription, installing its filter
+// Returns the subscription id
+func (s *wsSession) subscribe(params []json.RawMessage) (interface{}, *jsonRpcError) {
+	var kind string
+	if len(params) == 0 || json.Unmarshal(params[0], &kind) != nil {
+		return nil, &jsonRpcError{Code: -32602, Message: "missing subscription kind"}
+	}
+	method, ok := subscriptionFilters[kind]
+	if !ok {
+		return nil, &jsonRpcError{Code: -32602, Message: fmt.Sprintf("unsupported subscription kind %s", kind)}
+	}
+	sub := &subscription{
+		id:       newSubscriptionId(),
+		kind:     kind,
+		method:   method,
+		stopChan: make(chan struct{}),
+	}
+	if kind == "logs" {
+		var options interface{} = map[string]interface{}{}
+		if len(params) > 1 {
+			options = params[1]
+		}
+		sub.params = []interface{}{options}
+	}
+	if err := s.install(sub); err != nil {
+		return nil, &jsonRpcError{Code: -32000, Message: err.Error()}
+	}
+
+	s.mux.Lock()
+	s.subscriptions[sub.id] = sub
+	s.mux.Unlock()
+	s.pollers.Add(1)
+	go s.run(sub)
+	jww.INFO.Printf("[%s] Subscription %s to %s on %s", s.logPrefix, sub.id, kind, s.network)
+	return sub.id, nil
+}
+
+// Remove a subscription, uninstalling its filter
+// Returns whether the subscription existed
+func (s *wsSession) unsubscribe(params []json.RawMessage) (interface{}, *jsonRpcError) {
+	var id string
+	if len(params) == 0 || json.Unmarshal(params[0], &id) != nil {
+		return nil, &jsonRpcError{Code: -32602, Message: "missing subscription id"}
+	}
+	s.mux.Lock()
+	sub, ok := s.subscriptions[id]
+	delete(s.subscriptions, id)
+	s.mux.Unlock()
+	if !ok {
+		return false, nil
+	}
+	close(sub.stopChan)
+	jww.INFO.Printf("[%s] Subscription %s removed", s.logPrefix, id)
+	return true, nil
+}
+
+// Install the filter of a subscription
+func (s *wsSession) install(sub *subscription) error {
+	var filterId string
+	if err := callJsonRpc(s.api, s.network, sub.method, &filterId, sub.params...); err != nil {
+		return fmt.Errorf("error installing %s filter: %v", sub.kind, err)
+	}
+	sub.filterId = filterId
+	return nil
+}
+
+// Uninstall the filter of a subscription
+// Errors are ignored, since filters expire on their own
+func (s *wsSession) uninstall(sub *subscription) {
+	var ok bool
+	callJsonRpc(s.api, s.network, "eth_uninstallFilter", &ok, sub.filterId)
+}
+
+// Poll the filter of a subscription until it is stopped,
+// then uninstall the filter
+func (s *wsSession) run(sub *subscription) {
+	defer s.pollers.Done()
+	defer s.uninstall(sub)
+	ticker := time.NewTicker(s.poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sub.stopChan:
+			return
+		case <-ticker.C:
+			if !s.pollFilter(sub) {
+				return
+			}
+		}
+	}
+}
+
+// Get the filter changes of a subscription and notify them
+// Returns false if the connection can't be written to
+func (s *wsSession) pollFilter(sub *subscription) bool {
+	var changes []json.RawMessage
+	if err := callJsonRpc(s.api, s.network, "eth_getFilterChanges", &changes, sub.filterId); err != nil {
+		jww.WARN.Printf("[%s] Error polling subscription %s, installing filter again: %v", s.logPrefix, sub.id, err)
+		if err := s.install(sub); err != nil {
+			jww.WARN.Printf("[%s] Subscription %s: %v", s.logPrefix, sub.id, err)
+		}
+		return true
+	}
+	for _, change := range changes {
+		select {
+		case <-sub.stopChan:
+			return true
+		default:
+		}
+		// Block filters return hashes, while newHeads notifies headers
+		if sub.kind == "newHeads" {
+			var hash string
+			if err := json.Unmarshal(change, &hash); err != nil {
+				continue
+			}
+			var header json.RawMessage
+			if err := callJsonRpc(s.api, s.network, "eth_getBlockByHash", &header, hash, false); err != nil {
+				jww.WARN.Printf("[%s] Subscription %s: error getting block %s: %v", s.logPrefix, sub.id, hash, err)
+				continue
+			}
+			change = header
+		}
+		data, _ := json.Marshal(subscriptionNotification{
+			Jsonrpc: "2.0",
+			Method:  "eth_subscription",
+			Params:  subscriptionResult{Subscription: sub.id, Result: change},
+		})
+		if err := s.write(data); err != nil {
+			s.close()
+			return false
+		}
+	}
+	return true
+}
+
+// Generate a random subscription id
+func newSubscriptionId() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return "0x" + hex.EncodeToString(id)
+}
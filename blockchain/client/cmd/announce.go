@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/blockchain/client/api"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// Verify relay server announcements, signed by their operators
+// Relay servers from announcements are added to the contacts,
+// and every contact file must match a verified announcement,
+// otherwise it may impersonate a relay server
+// Announcements must be signed by one of the trusted keys,
+// since the key they carry only detects tampering, not impersonation
+// Relay servers from the contacts directory and the relay directory
+// aren't checked against announcements
+func verifyAnnouncements(contacts []api.ServerInfo) []api.ServerInfo {
+	// Parse trusted keys
+	if len(announceKeys) == 0 {
+		jww.FATAL.Panicf("[%s] Trusted announcement keys are required to verify announcements", settings.LogPrefix)
+	}
+	trusted := make(map[string]struct{}, len(announceKeys))
+	for _, k := range announceKeys {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(k))
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			jww.FATAL.Panicf("[%s] Invalid announcement key %s", settings.LogPrefix, k)
+		}
+		trusted[string(key)] = struct{}{}
+	}
+	if contactsDir != "" || directorySource != "" {
+		jww.WARN.Printf("[%s] Relay servers from the contacts directory or relay directory aren't checked against announcements", settings.LogPrefix)
+	}
+
+	// Verify announcements
+	announced := make([]contactAnnouncement, 0, len(announcementFiles))
+	for _, file := range announcementFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			jww.FATAL.Panicf("[%s] Failed to read announcement file: %+v", settings.LogPrefix, err)
+		}
+		a, err := cmix.VerifyAnnouncement(data)
+		if err != nil {
+			jww.FATAL.Panicf("[%s] Invalid announcement %s: %v", settings.LogPrefix, file, err)
+		}
+		if _, ok := trusted[string(a.Key)]; !ok {
+			jww.FATAL.Panicf("[%s] Announcement %s is signed by untrusted key %s", settings.LogPrefix, file, a.KeyString())
+		}
+		c, _ := a.ParseContact()
+		jww.INFO.Printf("[%s] Verified announcement %s of relay server operated by %s with key %s",
+			settings.LogPrefix, file, a.Operator.Name, a.KeyString())
+		announced = append(announced, contactAnnouncement{c.Marshal(), false})
+	}
+
+	// Check contact files against announcements
+	for _, info := range contacts {
+		c := cmix.LoadContactFile(info.ContactFile).Marshal()
+		matched := false
+		for i := range announced {
+			if bytes.Equal(announced[i].contact, c) {
+				announced[i].matched = true
+				matched = true
+			}
+		}
+		if !matched {
+			jww.FATAL.Panicf("[%s] Contact file %s doesn't match any announcement, it may impersonate a relay server",
+				settings.LogPrefix, info.ContactFile)
+		}
+	}
+
+	// Add announced relay servers without contact file
+	for _, a := range announced {
+		if a.matched {
+			continue
+		}
+		contacts = append(contacts, api.ServerInfo{
+			Contact: cmix.UnmarshalContact(a.contact),
+			Name:    fmt.Sprintf("relay-%d", len(contacts)),
+		})
+	}
+	return contacts
+}
+
+// Contact of a verified announcement
+type contactAnnouncement struct {
+	// Marshalled contact
+	contact []byte
+	// Whether a contact file matches it
+	matched bool
+}
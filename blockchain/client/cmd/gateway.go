@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/blockchain/client/api"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+	"github.com/xx-labs/blockchain-cmix-relay/common/command"
+)
+
+// ---------------------------- //
+// Gateway mode
+//
+// For tools that don't allow a path in the RPC URL, the client
+// can listen on additional ports, each bound to a single network,
+// configured with --networkPorts (e.g. /ethereum/mainnet=8545,polygon=8546)
+// Networks are given as URI, alias or chain ID path
+
+// HTTP proxy server bound to a single network
+type gateway struct {
+	network string
+	port    int
+	server  *api.HttpProxy
+}
+
+// Check the network ports don't conflict
+// with each other, the main port or the metrics port
+func validateNetworkPorts(ports map[string]int, mainPort, metricsPort int) {
+	used := map[int]string{mainPort: "the HTTP proxy server"}
+	if metricsPort != 0 {
+		if metricsPort == mainPort {
+			jww.FATAL.Panicf("[%s] Metrics port %d is already used by the HTTP proxy server", settings.LogPrefix, metricsPort)
+		}
+		used[metricsPort] = "the metrics server"
+	}
+	for _, network := range sortedNetworks(ports) {
+		p := ports[network]
+		if p <= 0 || p > 65535 {
+			jww.FATAL.Panicf("[%s] Invalid port %d for network %s", settings.LogPrefix, p, network)
+		}
+		if other, ok := used[p]; ok {
+			jww.FATAL.Panicf("[%s] Port %d of network %s is already used by %s", settings.LogPrefix, p, network, other)
+		}
+		used[p] = "network " + network
+	}
+}
+
+// Create an HTTP proxy server for each network port
+// Networks not supported by the connected relay servers
+// are still served, since they can be added later
+func newGateways(apiInstance *api.Api, requester api.Requester, logMode cmix.LogMode, config api.ProxyConfig) []gateway {
+	gateways := make([]gateway, 0, len(networkPorts))
+	for _, network := range sortedNetworks(networkPorts) {
+		if !isSupported(apiInstance, network) {
+			jww.WARN.Printf("[%s] Network %s of port %d is not supported by any relay server", settings.LogPrefix, network, networkPorts[network])
+		}
+		p := networkPorts[network]
+		gateways = append(gateways, gateway{
+			network: network,
+			port:    p,
+			server:  api.NewNetworkProxy(requester, p, network, settings.LogPrefix, logMode, config),
+		})
+	}
+	sort.Slice(gateways, func(i, j int) bool {
+		return gateways[i].port < gateways[j].port
+	})
+	return gateways
+}
+
+// Component running the gateway server
+func (g gateway) component() command.Component {
+	return command.Component{
+		Name:  fmt.Sprintf("HTTP proxy server for %s", g.network),
+		Start: func() { go g.server.Start() },
+		Stop:  g.server.Stop,
+	}
+}
+
+// Print the URL of each server and the network it serves
+func printGatewaySummary(out io.Writer, apiInstance *api.Api, server *api.HttpProxy, gateways []gateway) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tNETWORK")
+	fmt.Fprintf(w, "%s\tall networks, by path\n", server.Url())
+	for _, g := range gateways {
+		network := apiInstance.ResolveNetwork(g.network)
+		if !isSupported(apiInstance, g.network) {
+			network += " (not supported yet)"
+		} else if network != g.network {
+			network = fmt.Sprintf("%s (%s)", network, g.network)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", g.server.Url(), network)
+	}
+	w.Flush()
+}
+
+// Check if a network is supported by a connected relay server
+func isSupported(apiInstance *api.Api, network string) bool {
+	uri := apiInstance.ResolveNetwork(network)
+	for _, net := range apiInstance.Networks() {
+		if net == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// Networks of the network ports, sorted
+func sortedNetworks(ports map[string]int) []string {
+	networks := make([]string, 0, len(ports))
+	for network := range ports {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+	return networks
+}
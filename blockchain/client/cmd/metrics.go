@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/blockchain/client/api"
+	"github.com/xx-labs/blockchain-cmix-relay/common/command"
+)
+
+// ---------------------------- //
+// Metrics server
+//
+// The client side metrics, such as agreements and disagreements
+// of verified responses, are exposed in Prometheus format on
+// http://<address>:<metricsPort>/metrics, like the relay server metrics
+
+type MetricsServer struct {
+	port int
+	srv  *http.Server
+}
+
+func NewMetricsServer(metrics *api.Metrics, address string, port int) *MetricsServer {
+	ms := &MetricsServer{port, nil}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metrics)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	// Expose the client metrics via HTTP server
+	ms.srv = &http.Server{
+		Addr:    net.JoinHostPort(address, strconv.Itoa(port)),
+		Handler: mux,
+	}
+	return ms
+}
+
+func (s *MetricsServer) Start() {
+	jww.INFO.Printf("[%s] Starting metrics HTTP server on %s", settings.LogPrefix, s.srv.Addr)
+	if err := s.srv.ListenAndServe(); err != http.ErrServerClosed {
+		jww.FATAL.Panicf("[%s] Error starting metrics HTTP server: %v", settings.LogPrefix, err)
+	}
+}
+
+func (s *MetricsServer) Stop() {
+	jww.INFO.Printf("[%s] Stopping metrics HTTP server on port %d", settings.LogPrefix, s.port)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// Wait for requests in progress, then close remaining connections
+	if err := s.srv.Shutdown(ctx); err != nil {
+		jww.ERROR.Printf("[%s] Error stopping metrics HTTP server, closing connections: %v", settings.LogPrefix, err)
+		s.srv.Close()
+	}
+	jww.INFO.Printf("[%s] Metrics HTTP server stopped", settings.LogPrefix)
+}
+
+// Component running the metrics server
+func (s *MetricsServer) component() command.Component {
+	return command.Component{
+		Name:  "metrics server",
+		Start: func() { go s.Start() },
+		Stop:  s.Stop,
+	}
+}
@@ -1,170 +1,405 @@
 package cmd
 
 import (
+	"crypto/ed25519"
 	"fmt"
-	"io"
-	"os"
-	"os/signal"
-	"syscall"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	jww "github.com/spf13/jwalterweatherman"
 	"github.com/xx-labs/blockchain-cmix-relay/blockchain/client/api"
+	"github.com/xx-labs/blockchain-cmix-relay/blockchain/client/directory"
 	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+	"github.com/xx-labs/blockchain-cmix-relay/common/command"
+	"github.com/xx-labs/blockchain-cmix-relay/common/config"
+	"github.com/xx-labs/blockchain-cmix-relay/common/cors"
 )
 
-// Cmix state config variables are global and don't change
-var statePath string
-
-// Password is a mandatory flag
-var statePassword string
-
-var ndfUrl string
-var cert string
+// Shared settings of cMix commands
+var settings command.Settings
 
 // Server contact file
 var contactFiles []string
 
-// Logging flags
-var logLevel uint // 0 = info, 1 = debug, >1 = trace
-var logPath string
-var logPrefix string
+// Directory watched for server contact files
+var contactsDir string
+var contactsDirInterval time.Duration
+
+// Signed relay directory
+var directorySource string
+var directoryKey string
+var directoryRefresh time.Duration
+
+// Relay server announcements
+var announcementFiles []string
+var announceKeys []string
+
+// Logging of request and response data
+var logRequests string
 
 // Request retries
 var retries int
+var retryDelay time.Duration
+var retryMaxDelay time.Duration
+var retryTimeout time.Duration
+
+// Request hedging
+var hedgeRelays int
+var hedgeDelay time.Duration
+var hedgeWrites string
+
+// Response verification
+var verifyQuorum int
+var verifyMethods map[string]int
+
+// Proof verification
+var proofNetworks []string
+var proofCheckpoints map[string]string
+var proofQuorum int
+var proofConfirmations uint64
+
+// Request sanitizing
+var sanitizeIds bool
+var sanitizeFields bool
+var blockMethods []string
+
+// Privacy padding
+var paddingMode string
+var paddingBlockSize int
+
+// Cover traffic
+var coverRate float64
+var coverJitter time.Duration
+
+// Identity lifetime
+var identityPolicy string
+var identityRotateInterval time.Duration
+var identityRotateRequests uint64
 
 // Local HTTP proxy server port
 var port int
 
+// Metrics server port
+var metricsPort int
+
+// Local HTTP proxy server address, TLS and access control
+var address string
+var tlsEnabled bool
+var tlsCert string
+var tlsKey string
+var authToken string
+var authUser string
+var authPassword string
+
+// Browser origin policy of the local HTTP proxy servers
+var corsConfig cors.Config
+
+// Additional ports bound to a single network
+var networkPorts map[string]int
+
+// Interval between polls of WebSocket subscription filters
+var subscriptionPoll time.Duration
+
+// Network used for requests to /
+var defaultNetwork string
+
 // rootCmd represents the base command when called without any sub-commands
-var rootCmd = &cobra.Command{
-	Use:   "client",
-	Short: "Runs a blockchain cMix relay client",
-	Long:  `Client provides an HTTP server that proxies JSON-RPC requests over cMix to query/interact with supported blockchain networks`,
-	Args:  cobra.NoArgs,
-	Run: func(cmd *cobra.Command, args []string) {
-		// Initialize logging
-		initLog()
-
-		// Relay servers
-		serverContacts := make([]api.ServerInfo, len(contactFiles))
-		for i, contactFile := range contactFiles {
-			serverContacts[i] = api.ServerInfo{
-				ContactFile: contactFile,
-				Name:        fmt.Sprintf("relay-%d", i),
-			}
+var rootCmd = command.New(command.Options{
+	Use:       "client",
+	Short:     "Runs a blockchain cMix relay client",
+	Long:      `Client provides an HTTP server that proxies JSON-RPC requests over cMix to query/interact with supported blockchain networks`,
+	EnvPrefix: "CLIENT",
+	LogPrefix: "RELAY",
+	LogFile:   "client.log",
+	Run:       run,
+}, &settings)
+
+func run(cmd *cobra.Command, args []string) {
+	// Validate hedging policy for write requests
+	writePolicy := api.WritePolicy(hedgeWrites)
+	if writePolicy != api.WritePolicySingle && writePolicy != api.WritePolicyHedge &&
+		writePolicy != api.WritePolicyBroadcast {
+		jww.FATAL.Panicf("[%s] Invalid hedging policy for write requests: %s", settings.LogPrefix, hedgeWrites)
+	}
+
+	// Validate padding mode
+	padding, err := cmix.ParsePaddingMode(paddingMode)
+	if err != nil {
+		jww.FATAL.Panicf("[%s] %v", settings.LogPrefix, err)
+	}
+
+	// Validate identity policy
+	identity, err := cmix.ParseIdentityPolicy(identityPolicy)
+	if err != nil {
+		jww.FATAL.Panicf("[%s] %v", settings.LogPrefix, err)
+	}
+
+	// Validate request logging mode
+	logMode, err := cmix.ParseLogMode(logRequests)
+	if err != nil {
+		jww.FATAL.Panicf("[%s] %v", settings.LogPrefix, err)
+	}
+
+	// Validate network ports
+	validateNetworkPorts(networkPorts, port, metricsPort)
+
+	// Validate HTTP proxy access control
+	if (authUser == "") != (authPassword == "") {
+		jww.FATAL.Panicf("[%s] Both a user and a password are required for basic auth", settings.LogPrefix)
+	}
+	proxyConfig := api.ProxyConfig{
+		Address:          address,
+		Token:            authToken,
+		User:             authUser,
+		Password:         authPassword,
+		Origins:          cors.New(corsConfig, settings.LogPrefix),
+		SubscriptionPoll: subscriptionPoll,
+	}
+	if tlsEnabled {
+		cert, generated, err := api.LoadCertificate(tlsCert, tlsKey, []string{address})
+		if err != nil {
+			jww.FATAL.Panicf("[%s] %v", settings.LogPrefix, err)
 		}
+		if generated {
+			jww.INFO.Printf("[%s] Generated self-signed TLS certificate %s with SHA-256 fingerprint %s",
+				settings.LogPrefix, tlsCert, api.CertificateFingerprint(cert))
+		}
+		proxyConfig.Certificate = cert
+	}
 
-		// Create API
-		config := api.Config{
-			Cmix: cmix.Config{
-				LogPrefix:     logPrefix,
-				Cert:          cert,
-				NdfUrl:        ndfUrl,
-				StatePath:     statePath,
-				StatePassword: statePassword,
-			},
-			Retries:        retries,
-			ServerContacts: serverContacts,
+	// Validate relay directory key
+	var dirKey ed25519.PublicKey
+	if directorySource != "" {
+		if directoryKey == "" {
+			jww.FATAL.Panicf("[%s] A directory key is required to verify the relay directory", settings.LogPrefix)
 		}
-		apiInstance := api.NewApi(config)
+		dirKey, err = directory.ParsePublicKey(directoryKey)
+		if err != nil {
+			jww.FATAL.Panicf("[%s] %v", settings.LogPrefix, err)
+		}
+	}
 
-		// Connect API
-		apiInstance.Connect()
+	// Relay servers
+	// The default contact file isn't used with a contacts directory,
+	// a relay directory or announcements
+	if (contactsDir != "" || directorySource != "" || len(announcementFiles) > 0) && !cmd.Flags().Changed("contactFiles") {
+		contactFiles = nil
+	}
+	serverContacts := make([]api.ServerInfo, len(contactFiles))
+	for i, contactFile := range contactFiles {
+		serverContacts[i] = api.ServerInfo{
+			ContactFile: contactFile,
+			Name:        fmt.Sprintf("relay-%d", i),
+		}
+	}
+	if len(announcementFiles) > 0 {
+		serverContacts = verifyAnnouncements(serverContacts)
+	}
 
-		// Create HTTP proxy server
-		server := api.NewHttpProxy(apiInstance, port, logPrefix)
+	// cMix config
+	cmixConfig := settings.CmixConfig()
+	cmixConfig.Identity = cmix.IdentityConfig{
+		Policy:         identity,
+		RotateInterval: identityRotateInterval,
+		RotateRequests: identityRotateRequests,
+	}
 
-		// Print supported networks
-		networks := apiInstance.Networks()
-		jww.INFO.Printf("[%s] Supported networks", logPrefix)
-		for _, net := range networks {
-			jww.INFO.Printf("[%s] http://localhost:%d%s", logPrefix, port, net)
-		}
+	// Create API
+	config := api.Config{
+		Cmix:    cmixConfig,
+		Retries: retries,
+		Retry: api.RetryPolicy{
+			InitialDelay: retryDelay,
+			MaxDelay:     retryMaxDelay,
+			MaxElapsed:   retryTimeout,
+		},
+		Hedge: api.HedgeConfig{
+			Relays:      hedgeRelays,
+			Delay:       hedgeDelay,
+			WritePolicy: writePolicy,
+		},
+		Verify: api.VerifyConfig{
+			Quorum:  verifyQuorum,
+			Methods: verifyMethods,
+		},
+		Sanitize: api.SanitizeConfig{
+			NormalizeIds:   sanitizeIds,
+			StripFields:    sanitizeFields,
+			BlockedMethods: blockMethods,
+		},
+		Padding: cmix.Padding{
+			Mode:      padding,
+			BlockSize: paddingBlockSize,
+		},
+		Cover: api.CoverConfig{
+			Rate:      coverRate,
+			MaxJitter: coverJitter,
+		},
+		DefaultNetwork:      defaultNetwork,
+		ServerContacts:      serverContacts,
+		ContactsDir:         contactsDir,
+		ContactsDirInterval: contactsDirInterval,
+		Directory: api.DirectoryConfig{
+			Source:  directorySource,
+			Key:     dirKey,
+			Refresh: directoryRefresh,
+			// Stored next to the cMix state, since ephemeral
+			// identities don't use the state directory
+			IssuedFile: settings.StatePath + ".directory",
+		},
+	}
+	apiInstance := api.NewApi(config)
 
-		// Start server
-		go server.Start()
+	// Connect API
+	apiInstance.Connect()
 
-		// Handle shutdown
-		done := make(chan os.Signal, 1)
-		signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	// Verify state reads with proofs if configured
+	var requester api.Requester = apiInstance
+	if len(proofNetworks) > 0 {
+		requester = api.NewVerifier(apiInstance, api.ProofConfig{
+			Networks:      proofNetworks,
+			Checkpoints:   proofCheckpoints,
+			Quorum:        proofQuorum,
+			Confirmations: proofConfirmations,
+		})
+	}
 
-		// Block until signal
-		<-done
+	// Create HTTP proxy server
+	server := api.NewHttpProxy(requester, port, settings.LogPrefix, logMode, proxyConfig)
 
-		// Stop HTTP server
-		server.Stop()
+	// Create HTTP proxy servers bound to a single network
+	gateways := newGateways(apiInstance, requester, logMode, proxyConfig)
 
-		// Disconnect API
-		apiInstance.Disconnect()
+	// Print supported networks, with their
+	// chain ID and alias paths
+	networks := apiInstance.NetworkInfos()
+	jww.INFO.Printf("[%s] Supported networks", settings.LogPrefix)
+	for _, net := range networks {
+		paths := make([]string, 0, len(net.Aliases)+1)
+		if net.ChainId != 0 {
+			paths = append(paths, fmt.Sprintf("/chain/%d", net.ChainId))
+		}
+		for _, alias := range net.Aliases {
+			paths = append(paths, "/"+alias)
+		}
+		if len(paths) > 0 {
+			jww.INFO.Printf("[%s] %s%s (also %s)", settings.LogPrefix, server.Url(), net.Uri, strings.Join(paths, ", "))
+		} else {
+			jww.INFO.Printf("[%s] %s%s", settings.LogPrefix, server.Url(), net.Uri)
+		}
+	}
+	if defaultNetwork != "" {
+		jww.INFO.Printf("[%s] Default network %s/ is %s", settings.LogPrefix, server.Url(), apiInstance.ResolveNetwork("/"))
+	}
+
+	// Print the ports of each network in gateway mode
+	if len(gateways) > 0 {
+		printGatewaySummary(cmd.OutOrStdout(), apiInstance, server, gateways)
+	}
 
-		time.Sleep(2 * time.Second)
-	},
+	// Run HTTP proxy servers until a signal is received,
+	// then disconnect API
+	components := []command.Component{
+		{
+			Name: "API",
+			Stop: apiInstance.Disconnect,
+		},
+		{
+			Name:  "HTTP proxy server",
+			Start: func() { go server.Start() },
+			Stop:  server.Stop,
+		},
+	}
+	for _, g := range gateways {
+		components = append(components, g.component())
+	}
+	if metricsPort != 0 {
+		components = append(components, NewMetricsServer(apiInstance.Metrics(), address, metricsPort).component())
+	}
+	settings.Serve(components...)
 }
 
 // Execute adds all child commands to the root command and sets flags
 // appropriately.  This is called by main.main(). It only needs to
 // happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		jww.ERROR.Printf("[%s] Client exiting with error: %s", logPrefix, err.Error())
-		os.Exit(1)
-	}
-	jww.INFO.Printf("[%s] Client exiting without error...", logPrefix)
+	command.Execute(rootCmd, &settings)
 }
 
 // init is the initialization function for Cobra which defines commands
 // and flags.
 func init() {
-	// Set flags
+	// Relay directory tools
+	rootCmd.AddCommand(directory.NewCommand())
 
-	// cMix state
-	rootCmd.Flags().StringVarP(&statePath, "statePath", "s", "state", "Path cMix state directory")
-	rootCmd.Flags().StringVarP(&statePassword, "statePassword", "p", "", "Password for cMix state")
-	rootCmd.MarkFlagRequired("statePassword")
-	rootCmd.Flags().StringVarP(&ndfUrl, "ndf", "d",
-		"https://elixxir-bins.s3.us-west-1.amazonaws.com/ndf/mainnet.json",
-		"URL used to download NDF file on initialization",
-	)
-	rootCmd.Flags().StringVarP(&cert, "cert", "r",
-		"mainnet.crt",
-		"Path to certificate file used to verify NDF download",
-	)
+	// Set flags
 
 	// Contact file
 	rootCmd.Flags().StringArrayVarP(&contactFiles, "contactFiles", "c", []string{"relay.xxc"}, "List of paths to files containing the REST server contact info")
+	rootCmd.Flags().StringVarP(&contactsDir, "contactsDir", "", "", "Directory of REST server contact files (*.xxc), relay servers are added and removed as files are added and removed")
+	rootCmd.Flags().DurationVarP(&contactsDirInterval, "contactsDirInterval", "", 10*time.Second, "Interval between checks of the contacts directory")
+	// Relay directory
+	rootCmd.Flags().StringVarP(&directorySource, "directory", "", "", "File path or URL of a signed relay directory, relay servers are added and removed as the directory changes")
+	rootCmd.Flags().StringVarP(&directoryKey, "directoryKey", "", "", "Base64 encoded public key of the relay directory publisher")
+	rootCmd.Flags().DurationVarP(&directoryRefresh, "directoryRefresh", "", time.Hour, "Interval between refreshes of the relay directory")
+	// Announcements
+	rootCmd.Flags().StringArrayVarP(&announcementFiles, "announcements", "", nil, "List of paths to signed relay server announcements, contact files must match one of them")
+	rootCmd.Flags().StringSliceVarP(&announceKeys, "announceKeys", "", nil, "Base64 encoded public keys of trusted relay server operators, announcements must be signed by one of them (required with --announcements)")
 	// Retries
 	rootCmd.Flags().IntVarP(&retries, "retries", "n", 3, "How many times to retry sending request over cMix")
+	rootCmd.Flags().DurationVarP(&retryDelay, "retryDelay", "", 500*time.Millisecond, "Initial delay between retries, doubled on each retry")
+	rootCmd.Flags().DurationVarP(&retryMaxDelay, "retryMaxDelay", "", 10*time.Second, "Maximum delay between retries")
+	rootCmd.Flags().DurationVarP(&retryTimeout, "retryTimeout", "", 2*time.Minute, "Maximum total time spent retrying a request (0 = no limit)")
+	// Hedging
+	rootCmd.Flags().IntVarP(&hedgeRelays, "hedgeRelays", "", 1, "Maximum number of relay servers each request is sent to in parallel (1 = no hedging)")
+	rootCmd.Flags().DurationVarP(&hedgeDelay, "hedgeDelay", "", 3*time.Second, "Delay before sending a request to the next relay server when hedging (0 = send to all at once)")
+	rootCmd.Flags().StringVarP(&hedgeWrites, "hedgeWrites", "", string(api.WritePolicySingle), "Policy for requests that submit transactions (single, hedge, broadcast to all relay servers)")
+	// Verification
+	rootCmd.Flags().IntVarP(&verifyQuorum, "verifyQuorum", "", 1, "Number of relay servers that must agree on the response to a read request (1 = no verification)")
+	rootCmd.Flags().StringToIntVarP(&verifyMethods, "verifyMethods", "", nil, "Per method verification quorum, overriding verifyQuorum (e.g. eth_getBalance=3,eth_chainId=1)")
+	// Proof verification
+	rootCmd.Flags().StringArrayVarP(&proofNetworks, "proofNetworks", "", nil, "Networks for which state reads are verified with Merkle-Patricia proofs (e.g. /ethereum/mainnet)")
+	rootCmd.Flags().StringToStringVarP(&proofCheckpoints, "proofCheckpoints", "", nil, "Trusted checkpoint block hash per network, instead of a quorum of relay servers, only reads at the checkpoint block number are verified (e.g. /ethereum/mainnet=0x...)")
+	rootCmd.Flags().IntVarP(&proofQuorum, "proofQuorum", "", 2, "Number of relay servers that must agree on the latest block number and the trusted block header")
+	rootCmd.Flags().Uint64VarP(&proofConfirmations, "proofConfirmations", "", 2, "Number of blocks behind the latest block used as trusted block header, and maximum difference between the latest blocks of relay servers")
+	// Sanitizing
+	rootCmd.Flags().BoolVarP(&sanitizeIds, "sanitizeIds", "", true, "Replace JSON-RPC request ids with sequential ids, restoring them in the response")
+	rootCmd.Flags().BoolVarP(&sanitizeFields, "sanitizeFields", "", false, "Remove optional fields that can identify the user, such as the from field of eth_call")
+	rootCmd.Flags().StringSliceVarP(&blockMethods, "blockMethods", "", nil, "JSON-RPC methods answered locally with an error instead of being sent (e.g. "+strings.Join(api.IdentifyingMethods, ",")+")")
+	// Padding
+	rootCmd.Flags().StringVarP(&paddingMode, "padding", "", string(cmix.PaddingNone), "Padding of requests sent over cMix, to hide their size (none, buckets, block)")
+	rootCmd.Flags().IntVarP(&paddingBlockSize, "paddingBlockSize", "", cmix.DefaultBlockSize, "Block size for block padding, requests are padded to a multiple of this size")
+	// Cover traffic
+	rootCmd.Flags().Float64VarP(&coverRate, "coverRate", "", 0, "Average number of dummy requests sent per minute at random times (0 = no cover traffic)")
+	rootCmd.Flags().DurationVarP(&coverJitter, "coverJitter", "", 0, "Maximum random delay added to each request (0 = no delay)")
+	// Identity
+	rootCmd.Flags().StringVarP(&identityPolicy, "identity", "", string(cmix.IdentityPersistent), "Lifetime of the cMix identity (persistent, session = new identity on each start, rotate = new identity periodically)")
+	rootCmd.Flags().DurationVarP(&identityRotateInterval, "identityRotateInterval", "", 0, "Rotate the identity after this interval, for rotate identity (0 = no time based rotation)")
+	rootCmd.Flags().Uint64VarP(&identityRotateRequests, "identityRotateRequests", "", 0, "Rotate the identity after this number of requests, for rotate identity (0 = no request based rotation)")
 	// Port
 	rootCmd.Flags().IntVarP(&port, "port", "t", 9296, "Port to listen on for local HTTP proxy server")
+	// Metrics
+	rootCmd.Flags().IntVarP(&metricsPort, "metricsPort", "", 0, "Port for metrics server, on the HTTP proxy address (0 = disabled)")
+	// WebSocket subscriptions
+	rootCmd.Flags().DurationVarP(&subscriptionPoll, "subscriptionPoll", "", api.DefaultSubscriptionPoll, "Interval between polls of the filters of WebSocket subscriptions")
+	// Address, TLS and access control
+	rootCmd.Flags().StringVarP(&address, "address", "", api.DefaultAddress, "Address to listen on for local HTTP proxy servers (0.0.0.0 = all interfaces)")
+	rootCmd.Flags().BoolVarP(&tlsEnabled, "tls", "", false, "Serve HTTPS, with a self-signed certificate generated if the certificate files don't exist")
+	rootCmd.Flags().StringVarP(&tlsCert, "tlsCert", "", "client.crt", "Path to TLS certificate file")
+	rootCmd.Flags().StringVarP(&tlsKey, "tlsKey", "", "client.key", "Path to TLS key file")
+	rootCmd.Flags().StringVarP(&authToken, "authToken", "", "", "Bearer token required in the Authorization header of requests")
+	rootCmd.Flags().StringVarP(&authUser, "authUser", "", "", "User required with HTTP basic auth")
+	rootCmd.Flags().StringVarP(&authPassword, "authPassword", "", "", "Password required with HTTP basic auth")
+	config.MarkSecret(rootCmd.Flags(), "authToken")
+	config.MarkSecret(rootCmd.Flags(), "authPassword")
+	// Browser origin policy
+	cors.AddFlags(rootCmd.Flags(), &corsConfig)
+	// Gateway mode
+	rootCmd.Flags().StringToIntVarP(&networkPorts, "networkPorts", "", nil, "Additional ports bound to a single network, for tools that don't allow a path in the RPC URL (e.g. /ethereum/mainnet=8545,polygon=8546)")
+	// Default network
+	rootCmd.Flags().StringVarP(&defaultNetwork, "defaultNetwork", "", "", "Network used for requests to / (network URI, alias or /chain/<id>)")
 
 	// Logging
-	rootCmd.Flags().UintVarP(&logLevel, "logLevel", "l", 0, "Level of debugging to print (0 = info, 1 = debug, >1 = trace).")
-	rootCmd.Flags().StringVarP(&logPath, "logFile", "f", "client.log", "Path to log file")
-	rootCmd.Flags().StringVarP(&logPrefix, "logPrefix", "", "RELAY", "Logging prefix")
-}
-
-// initLog initializes logging thresholds and the log path.
-func initLog() {
-	// Check the level of logs to display
-	if logLevel > 1 {
-		// Turn on trace logs
-		jww.SetLogThreshold(jww.LevelTrace)
-	} else if logLevel == 1 {
-		// Turn on debugging logs
-		jww.SetLogThreshold(jww.LevelDebug)
-	} else {
-		// Turn on info logs
-		jww.SetLogThreshold(jww.LevelInfo)
-	}
-
-	// Create log file, overwrites if existing
-	logFile, err := os.Create(logPath)
-	if err != nil {
-		fmt.Printf("[%v] Could not open log file %s!\n", logPrefix, logPath)
-	} else {
-		jww.SetLogOutput(logFile)
-		jww.SetStdoutOutput(io.Discard)
-	}
+	rootCmd.Flags().StringVarP(&logRequests, "logRequests", "", string(cmix.LogMetadata), "Logging of requests and responses (full = bodies logged at trace level, metadata = sizes only, none)")
 }
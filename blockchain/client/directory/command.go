@@ -0,0 +1,186 @@
+package directory
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xx-labs/blockchain-cmix-relay/common/config"
+)
+
+// ---------------------------- //
+// Create the directory subcommand, with
+// the tools to publish and check relay directories
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "directory",
+		Short: "Create, sign and verify relay directories",
+		Long:  `This command provides tools to publish a signed relay directory, listing relay servers for clients, and to verify it`,
+		// Directory tools don't use the cMix state
+		PersistentPreRunE: config.SkipRequired,
+	}
+	cmd.AddCommand(newKeygenCommand(), newSignCommand(), newVerifyCommand())
+	return cmd
+}
+
+// Create the keygen subcommand
+func newKeygenCommand() *cobra.Command {
+	var keyFile string
+	cmd := &cobra.Command{
+		Use:   "keygen",
+		Args:  cobra.NoArgs,
+		Short: "Generate a directory signing key",
+		Long:  `This command generates an ed25519 key pair, writes the private key to a file, and prints the public key that clients pin with --directoryKey`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			public, private, err := ed25519.GenerateKey(rand.Reader)
+			if err != nil {
+				return err
+			}
+			data := base64.StdEncoding.EncodeToString(private) + "\n"
+			// Don't overwrite an existing key
+			f, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+			if err != nil {
+				return err
+			}
+			if _, err = f.WriteString(data); err != nil {
+				f.Close()
+				return err
+			}
+			if err = f.Close(); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), base64.StdEncoding.EncodeToString(public))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&keyFile, "keyFile", "k", "directory.key", "Path to output private key file")
+	return cmd
+}
+
+// Relay in the input of the sign subcommand
+// The contact can be given as a contact file instead
+type inputRelay struct {
+	Relay
+	ContactFile string `json:"contactFile,omitempty"`
+}
+
+// Create the sign subcommand
+func newSignCommand() *cobra.Command {
+	var keyFile, outputFile string
+	var validFor time.Duration
+	cmd := &cobra.Command{
+		Use:   "sign [input file]",
+		Args:  cobra.ExactArgs(1),
+		Short: "Sign a relay directory",
+		Long: `This command signs a relay directory, given as a JSON file with the relays field of the directory format
+Relay contacts can be given as a path to a contact file in a contactFile field, instead of the contact field
+The version and issue time are set automatically`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keyData, err := os.ReadFile(keyFile)
+			if err != nil {
+				return err
+			}
+			key, err := ParsePrivateKey(string(keyData))
+			if err != nil {
+				return err
+			}
+
+			// Read input
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			var input struct {
+				Relays []inputRelay `json:"relays"`
+			}
+			if err = json.Unmarshal(data, &input); err != nil {
+				return fmt.Errorf("invalid input directory: %v", err)
+			}
+
+			// Build directory
+			d := &Directory{
+				Version: Version,
+				Issued:  time.Now().UTC().Truncate(time.Second),
+				Relays:  make([]Relay, len(input.Relays)),
+			}
+			if validFor > 0 {
+				d.Expires = d.Issued.Add(validFor)
+			}
+			for i, r := range input.Relays {
+				if r.ContactFile != "" {
+					if r.Contact, err = os.ReadFile(r.ContactFile); err != nil {
+						return err
+					}
+				}
+				if r.ProtocolVersion == 0 {
+					r.ProtocolVersion = ProtocolVersion
+				}
+				d.Relays[i] = r.Relay
+			}
+
+			// Sign and check the result
+			signed, err := Sign(d, key)
+			if err != nil {
+				return err
+			}
+			if _, err = Verify(signed, key.Public().(ed25519.PublicKey)); err != nil {
+				return err
+			}
+			if err = os.WriteFile(outputFile, append(signed, '\n'), 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Signed directory with %d relays written to %s\n", len(d.Relays), outputFile)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&keyFile, "keyFile", "k", "directory.key", "Path to private key file")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "directory.json", "Path to output signed directory file")
+	cmd.Flags().DurationVarP(&validFor, "validFor", "", 30*24*time.Hour, "Time the directory is valid for after signing (0 = doesn't expire)")
+	return cmd
+}
+
+// Create the verify subcommand
+func newVerifyCommand() *cobra.Command {
+	var publicKey string
+	cmd := &cobra.Command{
+		Use:   "verify [file or URL]",
+		Args:  cobra.ExactArgs(1),
+		Short: "Verify a signed relay directory",
+		Long:  `This command loads a signed relay directory from a file or URL, verifies it with the given public key, and prints the relays it lists`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if publicKey == "" {
+				return errors.New("required flag \"key\" not set")
+			}
+			key, err := ParsePublicKey(publicKey)
+			if err != nil {
+				return err
+			}
+			d, err := Load(args[0], key)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Directory issued %v", d.Issued)
+			if !d.Expires.IsZero() {
+				fmt.Fprintf(out, ", expires %v", d.Expires)
+			}
+			fmt.Fprintln(out)
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tOPERATOR\tPROTOCOL\tNETWORKS")
+			for _, r := range d.Relays {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", r.Name, r.Operator, r.ProtocolVersion, strings.Join(r.Networks, ","))
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVarP(&publicKey, "key", "k", "", "Base64 encoded public key of the directory publisher")
+	return cmd
+}
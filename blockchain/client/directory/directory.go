@@ -0,0 +1,232 @@
+package directory
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gitlab.com/elixxir/crypto/contact"
+)
+
+// ---------------------------- //
+// Relay directory
+//
+// A relay directory is a signed JSON document listing relay servers,
+// so that clients can discover them without obtaining contact files
+// out of band
+// The directory is signed with an ed25519 key, and clients verify it
+// against the pinned public key of the publisher
+//
+// Signed directory format:
+//
+//	{
+//	  "directory": {
+//	    "version": 1,
+//	    "issued": "2023-05-01T00:00:00Z",
+//	    "expires": "2023-06-01T00:00:00Z",
+//	    "relays": [
+//	      {
+//	        "name": "xxlabs-1",
+//	        "operator": "xx labs",
+//	        "contact": "<base64 marshalled contact>",
+//	        "networks": ["/ethereum/mainnet"],
+//	        "protocolVersion": 1
+//	      }
+//	    ]
+//	  },
+//	  "signature": "<base64 ed25519 signature of the directory value>"
+//	}
+//
+// The signature covers the compact JSON encoding of the directory value,
+// so that the document can be reformatted without invalidating it
+
+// Version of the directory format
+const Version = 1
+
+// Version of the relay protocol supported by this client
+// Relays with a higher protocol version are skipped
+const ProtocolVersion = 1
+
+// Maximum size of a directory document
+const maxSize = 1 << 20
+
+// Directory lists relay servers
+type Directory struct {
+	// Version of the directory format
+	Version int `json:"version"`
+
+	// Time the directory was issued
+	// Clients don't replace a directory with an older one
+	Issued time.Time `json:"issued"`
+
+	// Time after which the directory is no longer valid
+	// The directory doesn't expire if zero
+	Expires time.Time `json:"expires,omitempty"`
+
+	// Relay servers
+	Relays []Relay `json:"relays"`
+}
+
+// Relay is a relay server in the directory
+type Relay struct {
+	// Unique name of the relay server
+	Name string `json:"name"`
+
+	// Name of the relay server operator
+	Operator string `json:"operator"`
+
+	// Marshalled cMix contact of the relay server
+	Contact []byte `json:"contact"`
+
+	// Networks supported by the relay server
+	// For information only, clients get supported
+	// networks from the relay server itself
+	Networks []string `json:"networks"`
+
+	// Version of the relay protocol used by the relay server
+	ProtocolVersion int `json:"protocolVersion"`
+}
+
+// Signed is a directory with its signature
+type Signed struct {
+	Directory json.RawMessage `json:"directory"`
+	Signature []byte          `json:"signature"`
+}
+
+// Errors of directory verification
+var (
+	ErrInvalidSignature = errors.New("invalid directory signature")
+	ErrExpired          = errors.New("directory expired")
+)
+
+// ---------------------------- //
+// Sign a directory with the given private key
+// Returns the signed directory document
+func Sign(d *Directory, key ed25519.PrivateKey) ([]byte, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(Signed{
+		Directory: data,
+		Signature: ed25519.Sign(key, data),
+	}, "", "  ")
+}
+
+// ---------------------------- //
+// Verify a signed directory document with the given public key
+// Returns the directory if the signature is valid,
+// the directory format is supported, it isn't expired
+// and all relay contacts are valid
+func Verify(data []byte, key ed25519.PublicKey) (*Directory, error) {
+	var signed Signed
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, fmt.Errorf("invalid signed directory: %v", err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, signed.Directory); err != nil {
+		return nil, fmt.Errorf("invalid directory: %v", err)
+	}
+	if !ed25519.Verify(key, compact.Bytes(), signed.Signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	var d Directory
+	if err := json.Unmarshal(signed.Directory, &d); err != nil {
+		return nil, fmt.Errorf("invalid directory: %v", err)
+	}
+	if d.Version != Version {
+		return nil, fmt.Errorf("unsupported directory version %d", d.Version)
+	}
+	if !d.Expires.IsZero() && time.Now().After(d.Expires) {
+		return nil, fmt.Errorf("%w at %v", ErrExpired, d.Expires)
+	}
+	names := make(map[string]struct{}, len(d.Relays))
+	for _, r := range d.Relays {
+		if r.Name == "" {
+			return nil, errors.New("relay without name in directory")
+		}
+		if _, ok := names[r.Name]; ok {
+			return nil, fmt.Errorf("duplicate relay %s in directory", r.Name)
+		}
+		names[r.Name] = struct{}{}
+		if _, err := r.ParseContact(); err != nil {
+			return nil, fmt.Errorf("invalid contact of relay %s: %v", r.Name, err)
+		}
+	}
+	return &d, nil
+}
+
+// ---------------------------- //
+// Load and verify a signed directory
+// from a file path or an HTTP(S) URL
+func Load(source string, key ed25519.PublicKey) (*Directory, error) {
+	data, err := read(source)
+	if err != nil {
+		return nil, err
+	}
+	return Verify(data, key)
+}
+
+// ---------------------------- //
+// Parse the cMix contact of a relay server
+func (r Relay) ParseContact() (contact.Contact, error) {
+	return contact.Unmarshal(r.Contact)
+}
+
+// ---------------------------- //
+// Whether the relay server protocol is supported by this client
+func (r Relay) Supported() bool {
+	return r.ProtocolVersion <= ProtocolVersion
+}
+
+// ---------------------------- //
+// Parse a base64 encoded ed25519 public key
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid directory key: %v", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid directory key size %d", len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// ---------------------------- //
+// Parse a base64 encoded ed25519 private key
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %v", err)
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid signing key size %d", len(key))
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// Read a document from a file path or an HTTP(S) URL
+func read(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSize))
+}
@@ -0,0 +1,173 @@
+package directory
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"gitlab.com/elixxir/crypto/contact"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+func newKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newRelay(t *testing.T, name string) Relay {
+	t.Helper()
+	relayId, err := id.NewRandomID(rand.Reader, id.User)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Relay{
+		Name:            name,
+		Operator:        "operator",
+		Contact:         contact.Contact{ID: relayId}.Marshal(),
+		Networks:        []string{"/ethereum/mainnet"},
+		ProtocolVersion: ProtocolVersion,
+	}
+}
+
+func newDirectory(t *testing.T, names ...string) *Directory {
+	t.Helper()
+	d := &Directory{
+		Version: Version,
+		Issued:  time.Now().UTC().Truncate(time.Second),
+		Relays:  make([]Relay, len(names)),
+	}
+	for i, name := range names {
+		d.Relays[i] = newRelay(t, name)
+	}
+	return d
+}
+
+func sign(t *testing.T, d *Directory, key ed25519.PrivateKey) []byte {
+	t.Helper()
+	data, err := Sign(d, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVerify(t *testing.T) {
+	key := newKey(t)
+	d := newDirectory(t, "relay-1", "relay-2")
+	d.Expires = d.Issued.Add(time.Hour)
+	verified, err := Verify(sign(t, d, key), key.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	if !verified.Issued.Equal(d.Issued) || !verified.Expires.Equal(d.Expires) || len(verified.Relays) != 2 {
+		t.Fatalf("Verify() = %+v, want %+v", verified, d)
+	}
+	for i, r := range verified.Relays {
+		if r.Name != d.Relays[i].Name || !bytes.Equal(r.Contact, d.Relays[i].Contact) {
+			t.Errorf("relay %d = %+v, want %+v", i, r, d.Relays[i])
+		}
+	}
+}
+
+// The signature covers the compact encoding,
+// so reformatting the document keeps it valid
+func TestVerifyReformatted(t *testing.T) {
+	key := newKey(t)
+	signed := sign(t, newDirectory(t, "relay-1"), key)
+	var compact, indented bytes.Buffer
+	if err := json.Compact(&compact, signed); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Indent(&indented, signed, "\t", "\t\t"); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"compact": compact.Bytes(), "indented": indented.Bytes()} {
+		if _, err := Verify(data, key.Public().(ed25519.PublicKey)); err != nil {
+			t.Errorf("Verify() of %s directory error: %v", name, err)
+		}
+	}
+}
+
+func TestVerifyBadSignature(t *testing.T) {
+	key := newKey(t)
+	signed := sign(t, newDirectory(t, "relay-1"), key)
+	tamper := []struct {
+		name string
+		data []byte
+		key  ed25519.PublicKey
+	}{
+		{"wrong key", signed, newKey(t).Public().(ed25519.PublicKey)},
+		{"modified directory", bytes.Replace(signed, []byte(`"operator"`), []byte(`"attacker"`), 1), key.Public().(ed25519.PublicKey)},
+		{"modified signature", modifySignature(t, signed), key.Public().(ed25519.PublicKey)},
+	}
+	for _, tt := range tamper {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Verify(tt.data, tt.key); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify() error = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+}
+
+func modifySignature(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var signed Signed
+	if err := json.Unmarshal(data, &signed); err != nil {
+		t.Fatal(err)
+	}
+	signed.Signature[0] ^= 0xff
+	modified, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return modified
+}
+
+func TestVerifyExpired(t *testing.T) {
+	key := newKey(t)
+	d := newDirectory(t, "relay-1")
+	d.Issued = d.Issued.Add(-2 * time.Hour)
+	d.Expires = d.Issued.Add(time.Hour)
+	if _, err := Verify(sign(t, d, key), key.Public().(ed25519.PublicKey)); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	key := newKey(t)
+	tests := []struct {
+		name   string
+		modify func(d *Directory)
+	}{
+		{"duplicate names", func(d *Directory) { d.Relays[1].Name = d.Relays[0].Name }},
+		{"missing name", func(d *Directory) { d.Relays[0].Name = "" }},
+		{"invalid contact", func(d *Directory) { d.Relays[0].Contact = []byte("not a contact") }},
+		{"unsupported version", func(d *Directory) { d.Version = Version + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDirectory(t, "relay-1", "relay-2")
+			tt.modify(d)
+			if _, err := Verify(sign(t, d, key), key.Public().(ed25519.PublicKey)); err == nil {
+				t.Error("Verify() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	key := newKey(t)
+	for _, data := range []string{``, `[]`, `{"directory":`, `{"directory":{"version":1,},"signature":""}`} {
+		if _, err := Verify([]byte(data), key.Public().(ed25519.PublicKey)); err == nil {
+			t.Errorf("Verify(%s) succeeded, want error", data)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+	"gitlab.com/elixxir/client/v4/restlike"
+)
+
+// ---------------------------- //
+// Create the announce subcommand
+func newAnnounceCommand() *cobra.Command {
+	var outputFile string
+	var operator cmix.Operator
+	var validFor time.Duration
+	cmd := &cobra.Command{
+		Use:   "announce",
+		Args:  cobra.NoArgs,
+		Short: "Write a signed announcement of the relay server",
+		Long: `This command writes a statement with the contact information, supported networks and operator of the relay server, signed with a key stored in the cMix state
+Clients that pin the printed public key can verify that a contact file belongs to this relay server`,
+		Run: func(cmd *cobra.Command, args []string) {
+			// Get supported networks from a network manager,
+			// which checks the networks endpoints once
+			manager := NewManager(readNetworksConfig(), restlike.NewEndpoints(), cmix.Padding{}, 0)
+
+			a := cmix.Announcement{
+				Networks: manager.Networks(),
+				Operator: operator,
+				Issued:   time.Now().UTC().Truncate(time.Second),
+			}
+			if validFor > 0 {
+				a.Expires = a.Issued.Add(validFor)
+			}
+			key := cmix.AnnounceServer(settings.CmixConfig(), a, outputFile)
+			fmt.Fprintln(cmd.OutOrStdout(), base64.StdEncoding.EncodeToString(key))
+		},
+	}
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "relay.announce.json", "Path to output announcement file")
+	cmd.Flags().StringVarP(&networksCfgFile, "networks", "n", "networks.json", "Path to networks configuration file")
+	cmd.Flags().StringVarP(&operator.Name, "operator", "", "", "Name of the relay server operator")
+	cmd.Flags().StringVarP(&operator.Url, "operatorUrl", "", "", "Website of the relay server operator")
+	cmd.Flags().StringVarP(&operator.Contact, "operatorContact", "", "", "Contact information of the relay server operator, such as an email address")
+	cmd.Flags().DurationVarP(&validFor, "validFor", "", 30*24*time.Hour, "Time the announcement is valid for (0 = doesn't expire)")
+	cmd.MarkFlagRequired("operator")
+	return cmd
+}
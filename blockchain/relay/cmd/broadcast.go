@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"errors"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// Result of a query to a single endpoint
+type queryResult struct {
+	endpoint int
+	data     []byte
+	code     int
+	err      error
+}
+
+// ---------------------------- //
+// Execute the query on all endpoints at once
+// Returns the first successful response without a JSON-RPC error
+// If all responses have errors, the first response is returned
+// The outcome for each endpoint is logged and
+// recorded in metrics as it arrives
+// Logs use the given prefix, which identifies the request
+func (n *Network) broadcastQuery(prefix string, endpoints []string, data []byte) ([]byte, int, error) {
+	jww.INFO.Printf("[%s] Broadcasting write request to %d endpoints", prefix, len(endpoints))
+
+	// Buffered so that queries finishing after returning don't block
+	results := make(chan queryResult, len(endpoints))
+	for idx, endpoint := range endpoints {
+		go func(idx int, endpoint string) {
+			resp, code, err := queryJsonRpc(endpoint, data)
+			result := "success"
+			if err != nil || code != 200 {
+				result = "failed"
+			} else if cmix.HasJsonRpcError(resp) {
+				result = "rpc_error"
+			}
+			jww.INFO.Printf("[%s] Broadcast to endpoint %d: %s (code %d)", prefix, idx, result, code)
+			n.metrics.IncBroadcastEndpoint(idx, result)
+			results <- queryResult{idx, resp, code, err}
+		}(idx, endpoint)
+	}
+
+	var first *queryResult
+	last := queryResult{code: 500, err: errors.New("no endpoint responded")}
+	for range endpoints {
+		res := <-results
+		if res.err != nil {
+			last = res
+			continue
+		}
+		if res.code == 200 && !cmix.HasJsonRpcError(res.data) {
+			return res.data, res.code, nil
+		}
+		if first == nil {
+			first = &res
+		}
+	}
+	if first != nil {
+		return first.data, first.code, nil
+	}
+	return nil, last.code, last.err
+}
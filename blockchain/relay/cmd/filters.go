@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"math/rand"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// ---------------------------- //
+// Filter routing to endpoints
+//
+// Requests are sent to a random endpoint, so filters are created on a
+// random endpoint, and requests using them are sent to that endpoint
+// Filters of endpoints that fail health checks are lost, and
+// clients get a filter not found error, so they can create them again
+
+// ---------------------------- //
+// Execute a filter request, on a random endpoint
+// for filter creation, or on the endpoint of the filter
+func (n *Network) filterQuery(prefix string, endpoints []string, req *cmix.FilterRequest, data []byte) ([]byte, int, error) {
+	if req.Creates() {
+		return n.createFilter(prefix, endpoints, req, data)
+	}
+	return n.useFilter(prefix, endpoints, req)
+}
+
+// Create a filter on a random endpoint
+func (n *Network) createFilter(prefix string, endpoints []string, req *cmix.FilterRequest, data []byte) ([]byte, int, error) {
+	if n.filters.Full() {
+		jww.WARN.Printf("[%s] Too many filters, refusing %s", prefix, req.Method)
+		return cmix.FilterError(req.Id, -32000, "too many filters"), 200, nil
+	}
+	endpoint := endpoints[rand.Intn(len(endpoints))]
+	resp, code, err := queryJsonRpc(endpoint, data)
+	if err != nil || code != 200 {
+		return resp, code, err
+	}
+	resp, id, err := n.filters.AddResponse(endpoint, "", resp)
+	if err != nil {
+		return nil, 500, err
+	}
+	if id != "" {
+		jww.INFO.Printf("[%s] Created filter %s", prefix, id)
+	}
+	return resp, code, nil
+}
+
+// Send a request using a filter to the endpoint with the filter
+func (n *Network) useFilter(prefix string, endpoints []string, req *cmix.FilterRequest) ([]byte, int, error) {
+	id, ok := req.FilterId()
+	if !ok {
+		return cmix.FilterError(req.Id, -32602, "missing filter id"), 200, nil
+	}
+	filter := n.filters.Get(id, req.Uninstalls())
+	if filter != nil && !contains(endpoints, filter.Target) {
+		jww.INFO.Printf("[%s] Filter %s is on an unhealthy endpoint", prefix, id)
+		n.filters.Remove(id)
+		filter = nil
+	}
+	if filter == nil {
+		return req.NotFound(), 200, nil
+	}
+	data, err := req.ForTarget(filter)
+	if err != nil {
+		return nil, 500, err
+	}
+	return queryJsonRpc(filter.Target, data)
+}
+
+// Check if a list of strings contains a string
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// ---------------------------- //
+// Endpoint verification
+//
+// Endpoints of networks with an expected chain ID or genesis hash
+// are checked to actually serve that network, when networks are
+// initialized and then periodically by health checks
+// Endpoints of other networks are only checked to be reachable
+// Chain ID and genesis hash checks only apply to JSON-RPC networks
+// Endpoints that serve a different network are excluded at once,
+// while endpoints that fail to respond are excluded after
+// several consecutive failed health checks, so that a transient
+// error doesn't exclude them
+// Failing and excluded endpoints are checked more often,
+// and included again when they pass
+
+// Timeout of each endpoint check
+const endpointCheckTimeout = 10 * time.Second
+
+// Consecutive failed health checks after which
+// an endpoint that doesn't respond is excluded
+const maxEndpointFailures = 3
+
+// Maximum interval between checks of failing and excluded endpoints
+const endpointRecheckInterval = 30 * time.Second
+
+// Errors of endpoint verification
+var (
+	errEndpointFailed   = errors.New("endpoint check failed")
+	errEndpointMismatch = errors.New("endpoint serves a different network")
+)
+
+// Number of failed checks of network endpoints that serve a different network
+var endpointMismatches = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "endpoints_mismatch_total",
+	Help: "Total number of endpoint checks that found an endpoint serving a different network, by network and endpoint",
+}, []string{"network", "endpoint"})
+
+// Client used for endpoint checks
+var checkClient = &http.Client{Timeout: endpointCheckTimeout}
+
+// JSON-RPC requests returning the genesis block hash, tried in order
+// until one of them succeeds
+var genesisRequests = []string{
+	// EVM
+	`{"id":1,"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x0",false]}`,
+	// Substrate
+	`{"id":1,"jsonrpc":"2.0","method":"chain_getBlockHash","params":[0]}`,
+	// Bitcoin
+	`{"id":1,"jsonrpc":"2.0","method":"getblockhash","params":[0]}`,
+}
+
+// Check that an endpoint serves the network it is configured for
+// Returns errEndpointMismatch if it serves a different chain ID
+// or genesis hash than expected
+func verifyEndpoint(endpoint string, adapter cmix.Adapter, config NetworkConfig) error {
+	switch adapter {
+	case cmix.AdapterRest:
+		return testConnectRest(endpoint)
+	case cmix.AdapterGraphQL:
+		return testConnectGraphQL(endpoint)
+	}
+	if config.ChainId == 0 && config.GenesisHash == "" {
+		if !testConnectJsonRpc(endpoint) {
+			return errEndpointFailed
+		}
+		return nil
+	}
+
+	// Chain ID
+	if config.ChainId != 0 {
+		var result string
+		err := callJsonRpc(endpoint, `{"id":1,"jsonrpc":"2.0","method":"eth_chainId","params":[]}`, &result)
+		if err != nil {
+			return err
+		}
+		chainId, err := strconv.ParseUint(result, 0, 64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid chain ID %q", errEndpointFailed, result)
+		}
+		if chainId != config.ChainId {
+			return fmt.Errorf("%w: chain ID %d, expected %d", errEndpointMismatch, chainId, config.ChainId)
+		}
+	}
+
+	// Genesis hash
+	if config.GenesisHash != "" {
+		hash, err := genesisHash(endpoint)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(strings.TrimPrefix(hash, "0x"), strings.TrimPrefix(config.GenesisHash, "0x")) {
+			return fmt.Errorf("%w: genesis hash %s, expected %s", errEndpointMismatch, hash, config.GenesisHash)
+		}
+	}
+	return nil
+}
+
+// Get the genesis block hash of the chain served by an endpoint
+func genesisHash(endpoint string) (string, error) {
+	var err error
+	for _, request := range genesisRequests {
+		// EVM returns the block, others the hash
+		var result json.RawMessage
+		if err = callJsonRpc(endpoint, request, &result); err != nil {
+			continue
+		}
+		var block struct {
+			Hash string `json:"hash"`
+		}
+		if json.Unmarshal(result, &block) == nil && block.Hash != "" {
+			return block.Hash, nil
+		}
+		var hash string
+		if json.Unmarshal(result, &hash) == nil && hash != "" {
+			return hash, nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("%w: no genesis hash returned", errEndpointFailed)
+	}
+	return "", err
+}
+
+// Test connection to a REST endpoint
+// Any response other than a server error is accepted,
+// since the endpoint base path might not be a resource
+func testConnectRest(endpoint string) error {
+	resp, err := checkClient.Get(endpoint)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errEndpointFailed, redactUrlError(err))
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%w: code %d", errEndpointFailed, resp.StatusCode)
+	}
+	return nil
+}
+
+// Test connection to a GraphQL endpoint
+// with a query that every schema supports
+func testConnectGraphQL(endpoint string) error {
+	resp, err := checkClient.Post(endpoint, "application/json", bytes.NewBufferString(`{"query":"{__typename}"}`))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errEndpointFailed, redactUrlError(err))
+	}
+	defer resp.Body.Close()
+	var response struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: code %d", errEndpointFailed, resp.StatusCode)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil || len(response.Data) == 0 {
+		return fmt.Errorf("%w: invalid GraphQL response", errEndpointFailed)
+	}
+	return nil
+}
+
+// Perform a JSON-RPC call for an endpoint check,
+// and unmarshal its result
+func callJsonRpc(endpoint string, request string, result interface{}) error {
+	resp, err := checkClient.Post(endpoint, "application/json", bytes.NewBufferString(request))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errEndpointFailed, redactUrlError(err))
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errEndpointFailed, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: code %d", errEndpointFailed, resp.StatusCode)
+	}
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err = json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("%w: invalid response: %v", errEndpointFailed, err)
+	}
+	if len(response.Error) > 0 && string(response.Error) != "null" {
+		return fmt.Errorf("%w: %s", errEndpointFailed, response.Error)
+	}
+	if err = json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("%w: invalid result: %v", errEndpointFailed, err)
+	}
+	return nil
+}
+
+// ---------------------------- //
+// Check all configured endpoints of the network,
+// and return the ones that pass
+// Mismatched endpoints are logged and counted in metrics
+func (n *Network) checkEndpoints() []string {
+	endpoints := make([]string, 0, len(n.config.Endpoints))
+	for idx, url := range n.config.Endpoints {
+		if err := n.checkEndpoint(idx, url); err == nil {
+			endpoints = append(endpoints, url)
+		} else {
+			n.logExcluded(url, err)
+		}
+	}
+	return endpoints
+}
+
+// Check an endpoint of the network
+// Mismatches are counted in metrics
+func (n *Network) checkEndpoint(idx int, url string) error {
+	err := verifyEndpoint(url, n.adapter, n.config)
+	if errors.Is(err, errEndpointMismatch) {
+		endpointMismatches.WithLabelValues(n.uri, strconv.Itoa(idx)).Inc()
+	}
+	return err
+}
+
+// Log the exclusion of an endpoint
+// Mismatched endpoints are logged as errors
+func (n *Network) logExcluded(url string, err error) {
+	if errors.Is(err, errEndpointMismatch) {
+		jww.ERROR.Printf("[%s] Network %v endpoint %v will be ignored: %v",
+			settings.LogPrefix, n.uri, cmix.RedactURL(url), err)
+	} else {
+		jww.INFO.Printf("[%s] Network %v endpoint %v will be ignored: %v",
+			settings.LogPrefix, n.uri, cmix.RedactURL(url), err)
+	}
+}
+
+// ---------------------------- //
+// Start checking the network endpoints periodically
+// All endpoints are checked every interval, and failing
+// and excluded endpoints every recheck interval in between
+// Health checks are disabled if the interval is zero
+func (n *Network) StartHealthChecks(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	recheck := interval
+	if recheck > endpointRecheckInterval {
+		recheck = endpointRecheckInterval
+	}
+	n.stopChan = make(chan struct{})
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ticker := time.NewTicker(recheck)
+		defer ticker.Stop()
+		var elapsed time.Duration
+		for {
+			select {
+			case <-n.stopChan:
+				return
+			case <-ticker.C:
+				elapsed += recheck
+				full := elapsed >= interval
+				if full {
+					elapsed = 0
+				}
+				n.healthCheck(full)
+			}
+		}
+	}()
+}
+
+// Stop the health checks and wait for them to return
+func (n *Network) StopHealthChecks() {
+	if n.stopChan == nil {
+		return
+	}
+	close(n.stopChan)
+	n.wg.Wait()
+	n.stopChan = nil
+}
+
+// Check the network endpoints and update the ones used
+// Only failing and excluded endpoints are checked if not full
+// Endpoints serving a different network are excluded at once,
+// and endpoints that don't respond after maxEndpointFailures
+// consecutive failed checks
+func (n *Network) healthCheck(full bool) {
+	if n.failures == nil {
+		n.failures = make(map[string]int)
+	}
+	n.mux.RLock()
+	included := make(map[string]bool, len(n.endpoints))
+	for _, url := range n.endpoints {
+		included[url] = true
+	}
+	n.mux.RUnlock()
+
+	endpoints := make([]string, 0, len(n.config.Endpoints))
+	for idx, url := range n.config.Endpoints {
+		if !full && included[url] && n.failures[url] == 0 {
+			endpoints = append(endpoints, url)
+			continue
+		}
+		err := n.checkEndpoint(idx, url)
+		if err == nil {
+			delete(n.failures, url)
+			endpoints = append(endpoints, url)
+			continue
+		}
+		n.failures[url]++
+		if !included[url] {
+			continue
+		}
+		if !errors.Is(err, errEndpointMismatch) && n.failures[url] < maxEndpointFailures {
+			jww.INFO.Printf("[%s] Network %v endpoint %v failed %d of %d checks before being ignored: %v",
+				settings.LogPrefix, n.uri, cmix.RedactURL(url), n.failures[url], maxEndpointFailures, err)
+			endpoints = append(endpoints, url)
+			continue
+		}
+		n.logExcluded(url, err)
+	}
+
+	n.mux.Lock()
+	previous := len(n.endpoints)
+	n.endpoints = endpoints
+	n.mux.Unlock()
+	if len(endpoints) != previous {
+		jww.INFO.Printf("[%s] Network %v has %d of %d endpoints healthy",
+			settings.LogPrefix, n.uri, len(endpoints), len(n.config.Endpoints))
+	}
+	if len(endpoints) == 0 && (full || previous != 0) {
+		jww.WARN.Printf("[%s] Network %v has no healthy endpoints", settings.LogPrefix, n.uri)
+	}
+}
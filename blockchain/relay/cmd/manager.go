@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"time"
 
 	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
 	"gitlab.com/elixxir/client/v4/restlike"
 )
 
@@ -14,6 +17,10 @@ type Manager struct {
 	networks  []*Network
 	endpoints *restlike.Endpoints
 	metrics   *Metrics
+	padding   cmix.Padding
+
+	// Interval between health checks of network endpoints
+	healthInterval time.Duration
 }
 
 // ---------------------------- //
@@ -22,15 +29,23 @@ type Manager struct {
 // Creates the network manager and
 // registers server endpoints with xxDK
 // for all supported networks
+// Responses to padded requests are padded
+// with the given padding
+// Network endpoints are checked with the given interval,
+// or only once if zero
 func NewManager(
 	networks map[string][]NetworkConfig,
 	endpoints *restlike.Endpoints,
+	padding cmix.Padding,
+	healthInterval time.Duration,
 ) *Manager {
 	// Create Manager
 	m := &Manager{
-		uri:       "/networks",
-		endpoints: endpoints,
-		metrics:   NewMetrics("/networks", MetricsKindNetworks),
+		uri:            "/networks",
+		endpoints:      endpoints,
+		metrics:        NewMetrics("/networks", MetricsKindNetworks),
+		padding:        padding,
+		healthInterval: healthInterval,
 	}
 	// Initialize networks
 	m.initNetworks(networks)
@@ -44,15 +59,19 @@ func NewManager(
 // Finally, initialize new supported networks
 func (m *Manager) Reload(networks map[string][]NetworkConfig) {
 
-	// Remove supported networks endpoint
+	// Remove supported networks and noop endpoints
 	m.endpoints.Remove(restlike.URI(m.uri), restlike.Get)
+	m.endpoints.Remove(restlike.URI(noopUri), restlike.Post)
 
 	// Remove all networks
 	for idx, net := range m.networks {
 		// Remove endpoint
 		m.endpoints.Remove(restlike.URI(net.uri), restlike.Post)
-		// Clear network
+		// Stop health checks and clear network
+		net.StopHealthChecks()
+		net.mux.Lock()
 		net.endpoints = nil
+		net.mux.Unlock()
 		m.networks[idx] = nil
 	}
 	m.networks = nil
@@ -61,15 +80,43 @@ func (m *Manager) Reload(networks map[string][]NetworkConfig) {
 	m.initNetworks(networks)
 }
 
+// ---------------------------- //
+// Stop the health checks of all networks
+func (m *Manager) Stop() {
+	for _, net := range m.networks {
+		net.StopHealthChecks()
+	}
+}
+
+// ---------------------------- //
+// Return the list of supported networks URIs
+func (m *Manager) Networks() []string {
+	networks := make([]string, len(m.networks))
+	for idx, net := range m.networks {
+		networks[idx] = net.uri
+	}
+	return networks
+}
+
+// ---------------------------- //
+// Return the descriptions of supported networks
+func (m *Manager) NetworkInfos() []cmix.NetworkInfo {
+	networks := make([]cmix.NetworkInfo, len(m.networks))
+	for idx, net := range m.networks {
+		networks[idx] = net.Info()
+	}
+	return networks
+}
+
 // ---------------------------- //
 // This is the callback function called by xxDK in order
 // to process a restlike request
 // This function returns a list of the supported networks
 func (m *Manager) Callback(request *restlike.Message) *restlike.Message {
-	jww.INFO.Printf("[%s %s] Request received over cMix: %v", logPrefix, m.uri, request)
+	requestLogMode.Log(settings.LogPrefix+" "+m.uri, "Request received over cMix", request.Content)
 	m.metrics.IncTotal()
 	if request.Uri != m.uri {
-		jww.WARN.Printf("[%s %s] Received URI (%v) doesn't match for this query!", logPrefix, m.uri, request.Uri)
+		jww.WARN.Printf("[%s %s] Received URI (%v) doesn't match for this query!", settings.LogPrefix, m.uri, request.Uri)
 	}
 
 	// Response
@@ -77,30 +124,46 @@ func (m *Manager) Callback(request *restlike.Message) *restlike.Message {
 	response.Headers = &restlike.Headers{}
 	response.Content = nil
 
-	// Get list of supported networks URIs
-	networks := make([]string, len(m.networks))
-	for idx, net := range m.networks {
-		networks[idx] = net.uri
+	// Convert list of supported networks to JSON data
+	// Clients that request version 2 get the chain ID and aliases
+	// of each network, others get the list of network URIs
+	var networks interface{} = m.Networks()
+	var listing cmix.NetworksRequest
+	if len(request.Content) > 0 && json.Unmarshal(request.Content, &listing) == nil && listing.Version >= 2 {
+		networks = m.NetworkInfos()
 	}
-
-	// Convert to JSON data
 	data, err := json.Marshal(networks)
 	if err != nil {
-		jww.ERROR.Printf("[%s %s] Error marshalling JSON data: %v", logPrefix, m.uri, err)
+		jww.ERROR.Printf("[%s %s] Error marshalling JSON data: %v", settings.LogPrefix, m.uri, err)
 		response.Error = "Internal server error"
 		m.metrics.IncFailedGeneric()
 	} else {
-		jww.INFO.Printf("[%s %s] Response: %v", logPrefix, m.uri, string(data))
+		requestLogMode.Log(settings.LogPrefix+" "+m.uri, "Response", data)
 		response.Content = data
 		m.metrics.IncSuccessful()
 	}
 	return response
 }
 
+// ---------------------------- //
+// This is the callback function called by xxDK in order
+// to process a restlike request to the noop endpoint
+// Clients send dummy requests to this endpoint as cover traffic,
+// so the request is discarded without logging or metrics
+func (m *Manager) NoopCallback(request *restlike.Message) *restlike.Message {
+	response := &restlike.Message{}
+	response.Headers = &restlike.Headers{Headers: make([]byte, 2)}
+	binary.LittleEndian.PutUint16(response.Headers.Headers, uint16(200))
+	return response
+}
+
 // ---------------------------- //
 // Internal functions
 // ---------------------------- //
 
+// URI of the endpoint that discards requests
+const noopUri = "/noop"
+
 func (m *Manager) initNetworks(networks map[string][]NetworkConfig) {
 	m.networks = make([]*Network, 0, len(networks))
 	// Create network representation for each
@@ -108,33 +171,81 @@ func (m *Manager) initNetworks(networks map[string][]NetworkConfig) {
 	for net, subnets := range networks {
 		for _, n := range subnets {
 			uri := "/" + net + "/" + n.Name
-			// Test endpoints
-			endpoints := make([]string, 0, len(n.Endpoints))
-			for _, url := range n.Endpoints {
-				if testConnectJsonRpc(url) {
-					endpoints = append(endpoints, url)
-				} else {
-					jww.INFO.Printf("[%s] Network %v endpoint %v is unreachable, will be ignored", logPrefix, uri, url)
-				}
+			if _, err := cmix.ParseAdapter(n.Adapter); err != nil {
+				jww.WARN.Printf("[%s] Network %v has %v, not supporting this network!", settings.LogPrefix, uri, err)
+				continue
 			}
-			if len(endpoints) == 0 {
-				jww.WARN.Printf("[%s] Network %v has no valid endpoints, not supporting this network!", logPrefix, uri)
+			// Check endpoints serve the expected network
+			network := NewNetwork(uri, nil, n)
+			network.endpoints = network.checkEndpoints()
+			if len(network.endpoints) == 0 {
+				jww.WARN.Printf("[%s] Network %v has no valid endpoints, not supporting this network!", settings.LogPrefix, uri)
 			} else {
-				network := NewNetwork(uri, endpoints)
 				m.networks = append(m.networks, network)
-				jww.INFO.Printf("[%s] Creating network: %v", logPrefix, uri)
-				m.endpoints.Add(restlike.URI(uri), restlike.Post, network.Callback)
+				jww.INFO.Printf("[%s] Creating network: %v", settings.LogPrefix, uri)
+				m.endpoints.Add(restlike.URI(uri), restlike.Post, m.padded(network.Callback))
+				network.StartHealthChecks(m.healthInterval)
 			}
 		}
 	}
 
+	// Warn about ambiguous chain IDs and aliases
+	m.checkNetworks()
+
 	// Add custom network
-	custom := NewNetwork("/custom", []string{})
+	custom := NewNetwork("/custom", []string{}, NetworkConfig{})
 	m.networks = append(m.networks, custom)
-	jww.INFO.Printf("[%s] Creating network: /custom", logPrefix)
-	m.endpoints.Add(restlike.URI("/custom"), restlike.Post, custom.Callback)
+	jww.INFO.Printf("[%s] Creating network: /custom", settings.LogPrefix)
+	m.endpoints.Add(restlike.URI("/custom"), restlike.Post, m.padded(custom.Callback))
+
+	// Register noop endpoint for cover traffic
+	jww.INFO.Printf("[%s] Creating endpoint: %s", settings.LogPrefix, noopUri)
+	m.endpoints.Add(restlike.URI(noopUri), restlike.Post, m.padded(m.NoopCallback))
 
 	// Register manager endpoint to get supported networks
-	jww.INFO.Printf("[%s] Creating endpoint: /networks", logPrefix)
-	m.endpoints.Add(restlike.URI(m.uri), restlike.Get, m.Callback)
+	jww.INFO.Printf("[%s] Creating endpoint: /networks", settings.LogPrefix)
+	m.endpoints.Add(restlike.URI(m.uri), restlike.Get, m.padded(m.Callback))
+}
+
+// Wrap a callback to handle padding
+// Padding is stripped from the request content
+// If the request was padded, and padding is enabled,
+// the response content is padded too
+func (m *Manager) padded(cb restlike.Callback) restlike.Callback {
+	return func(request *restlike.Message) *restlike.Message {
+		content, padded, err := cmix.Unpad(request.Content)
+		if err != nil {
+			jww.WARN.Printf("[%s %s] Invalid request padding: %v", settings.LogPrefix, request.Uri, err)
+			response := &restlike.Message{}
+			response.Headers = &restlike.Headers{Headers: make([]byte, 2)}
+			binary.LittleEndian.PutUint16(response.Headers.Headers, uint16(400))
+			response.Error = "Invalid request padding"
+			return response
+		}
+		request.Content = content
+		response := cb(request)
+		if padded && m.padding.Enabled() {
+			response.Content = m.padding.Pad(response.Content)
+		}
+		return response
+	}
+}
+
+// Warn about chain IDs and aliases shared by multiple networks,
+// since clients can't route them unambiguously
+func (m *Manager) checkNetworks() {
+	chainIds := make(map[uint64]string)
+	aliases := make(map[string]string)
+	for _, net := range m.networks {
+		if uri, ok := chainIds[net.chainId]; ok && net.chainId != 0 {
+			jww.WARN.Printf("[%s] Networks %v and %v have the same chain ID %d", settings.LogPrefix, uri, net.uri, net.chainId)
+		}
+		chainIds[net.chainId] = net.uri
+		for _, alias := range net.aliases {
+			if uri, ok := aliases[alias]; ok {
+				jww.WARN.Printf("[%s] Networks %v and %v have the same alias %v", settings.LogPrefix, uri, net.uri, alias)
+			}
+			aliases[alias] = net.uri
+		}
+	}
 }
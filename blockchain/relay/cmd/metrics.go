@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,7 +21,9 @@ type Metrics struct {
 	failed_invalid_url     prometheus.Counter // only for /custom endpoint
 	failed_unreachable_url prometheus.Counter // only for /custom endpoint
 	failed_rpc             prometheus.Counter
-	failed_generic         prometheus.Counter // only for /networks endpoint
+	failed_invalid_request prometheus.Counter     // only for generic endpoints
+	failed_generic         prometheus.Counter     // only for /networks endpoint
+	broadcast_endpoint     *prometheus.CounterVec // only for generic endpoints
 }
 
 type MetricsKind uint8
@@ -61,6 +64,17 @@ func NewMetrics(uri string, kind MetricsKind) *Metrics {
 			Help: fmt.Sprintf("Total number of failed requests for %s due to RPC error", uri),
 		})
 	}
+	// Only generic has broadcast_endpoint and failed_invalid_request
+	if kind == MetricsKindGeneric {
+		metrics.failed_invalid_request = promauto.NewCounter(prometheus.CounterOpts{
+			Name: fmt.Sprintf("requests%s_failed_invalid_request", mod_uri),
+			Help: fmt.Sprintf("Total number of failed requests for %s due to invalid REST request", uri),
+		})
+		metrics.broadcast_endpoint = promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: fmt.Sprintf("requests%s_broadcast_endpoint", mod_uri),
+			Help: fmt.Sprintf("Total number of broadcast requests for %s by endpoint and result", uri),
+		}, []string{"endpoint", "result"})
+	}
 	// Only /custom has failed_invalid_url and failed_unreachable_url
 	if kind == MetricsKindCustom {
 		metrics.failed_invalid_url = promauto.NewCounter(prometheus.CounterOpts{
@@ -99,10 +113,18 @@ func (m *Metrics) IncFailedRpc() {
 	m.failed_rpc.Inc()
 }
 
+func (m *Metrics) IncFailedInvalidRequest() {
+	m.failed_invalid_request.Inc()
+}
+
 func (m *Metrics) IncFailedGeneric() {
 	m.failed_generic.Inc()
 }
 
+func (m *Metrics) IncBroadcastEndpoint(endpoint int, result string) {
+	m.broadcast_endpoint.WithLabelValues(strconv.Itoa(endpoint), result).Inc()
+}
+
 type MetricsServer struct {
 	port int
 	srv  *http.Server
@@ -121,20 +143,22 @@ func NewMetricsServer(port int) *MetricsServer {
 }
 
 func (s *MetricsServer) Start() {
-	jww.INFO.Printf("[%s] Starting metrics HTTP server on port %d", logPrefix, s.port)
+	jww.INFO.Printf("[%s] Starting metrics HTTP server on port %d", settings.LogPrefix, s.port)
 	if err := s.srv.ListenAndServe(); err != http.ErrServerClosed {
-		jww.FATAL.Panicf("[%s] Error starting metrics HTTP server", logPrefix)
+		jww.FATAL.Panicf("[%s] Error starting metrics HTTP server", settings.LogPrefix)
 	}
 }
 
 func (s *MetricsServer) Stop() {
-	jww.INFO.Printf("[%s] Stopping metrics HTTP server on port %d", logPrefix, s.port)
+	jww.INFO.Printf("[%s] Stopping metrics HTTP server on port %d", settings.LogPrefix, s.port)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer func() {
 		cancel()
 	}()
+	// Wait for requests in progress, then close remaining connections
 	if err := s.srv.Shutdown(ctx); err != nil {
-		jww.FATAL.Panicf("[%s] Error stopping metrics HTTP server: %v", logPrefix, err)
+		jww.ERROR.Printf("[%s] Error stopping metrics HTTP server, closing connections: %v", settings.LogPrefix, err)
+		s.srv.Close()
 	}
-	jww.INFO.Printf("[%s] Metrics HTTP server stopped", logPrefix)
+	jww.INFO.Printf("[%s] Metrics HTTP server stopped", settings.LogPrefix)
 }
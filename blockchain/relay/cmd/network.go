@@ -3,8 +3,11 @@ package cmd
 import (
 	"encoding/binary"
 	"fmt"
+	"sync"
 
 	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+	"github.com/xx-labs/blockchain-cmix-relay/common/logging"
 	"gitlab.com/elixxir/client/v4/restlike"
 )
 
@@ -19,43 +22,106 @@ import (
 //
 // Multiple endpoints can be configured in order to
 // load balance requests
+//
+// Write requests (transaction submissions) can optionally
+// be broadcast to all endpoints
+//
+// Networks can have a chain ID and aliases, listed to clients
+// so that they can route requests without knowing the URI
+//
+// Endpoints are checked to serve the expected chain ID or
+// genesis hash, and only healthy endpoints are used
+//
+// Endpoints are queried with JSON-RPC by default,
+// or with the REST or GraphQL adapter
+//
+// Filter requests of JSON-RPC networks are sent to the
+// endpoint that created the filter
 type Network struct {
 	uri       string
+	config    NetworkConfig
+	adapter   cmix.Adapter
 	endpoints []string
+	broadcast bool
+	chainId   uint64
+	aliases   []string
 	metrics   *Metrics
+	filters   cmix.FilterRoutes
+	mux       sync.RWMutex
+
+	// Health checks
+	// Consecutive failed checks of each endpoint,
+	// only used by the health checks goroutine
+	failures map[string]int
+	stopChan chan struct{}
+	wg       sync.WaitGroup
 }
 
 // Configuration for a single network
 type NetworkConfig struct {
 	Name      string   `mapstructure:"name"`
 	Endpoints []string `mapstructure:"endpoints"`
+	Broadcast bool     `mapstructure:"broadcast"`
+	// Expected chain ID, returned by eth_chainId
+	ChainId uint64   `mapstructure:"chainId"`
+	Aliases []string `mapstructure:"aliases"`
+	// Expected genesis block hash, for chains without chain ID
+	GenesisHash string `mapstructure:"genesisHash"`
+	// Protocol of the endpoints (jsonrpc, rest, graphql)
+	Adapter string `mapstructure:"adapter"`
 }
 
 // ---------------------------- //
 // Constructor
-func NewNetwork(uri string, endpoints []string) *Network {
+// The adapter must be valid
+func NewNetwork(uri string, endpoints []string, config NetworkConfig) *Network {
 	kind := MetricsKindGeneric
 	if uri == "/custom" {
 		kind = MetricsKindCustom
 	}
+	adapter, _ := cmix.ParseAdapter(config.Adapter)
 	return &Network{
 		uri:       uri,
+		config:    config,
+		adapter:   adapter,
 		endpoints: endpoints,
+		broadcast: config.Broadcast,
+		chainId:   config.ChainId,
+		aliases:   config.Aliases,
 		metrics:   NewMetrics(uri, kind),
 	}
 }
 
+// ---------------------------- //
+// Get the description of the network listed to clients
+func (n *Network) Info() cmix.NetworkInfo {
+	info := cmix.NetworkInfo{
+		Uri:     n.uri,
+		ChainId: n.chainId,
+		Aliases: n.aliases,
+	}
+	if n.adapter != cmix.AdapterJsonRpc {
+		info.Adapter = n.adapter
+	}
+	return info
+}
+
 // ---------------------------- //
 // This is the callback function called by xxDK in order
 // to process a restlike request
 // This function will randomly choose one of the configured
 // blockchain endpoints, perform the query, and return the response
 // which is then sent back to the client over the cMix network
+// If broadcast is enabled, write requests are sent to all endpoints
+// Requests to REST networks are forwarded with the method and path
+// from the request headers
 func (n *Network) Callback(request *restlike.Message) *restlike.Message {
-	jww.INFO.Printf("[%s %s] Request received over cMix: %v", logPrefix, n.uri, request)
+	// Request id correlates the log lines of this request
+	prefix := logging.WithRequestId(settings.LogPrefix+" "+n.uri, logging.NewRequestId())
+	requestLogMode.Log(prefix, "Request received over cMix", request.Content)
 	n.metrics.IncTotal()
 	if request.Uri != n.uri {
-		jww.WARN.Printf("[%s %s] Received URI (%v) doesn't match for this query!", logPrefix, n.uri, request.Uri)
+		jww.WARN.Printf("[%s] Received URI (%v) doesn't match for this query!", prefix, request.Uri)
 	}
 
 	// Response
@@ -66,24 +132,40 @@ func (n *Network) Callback(request *restlike.Message) *restlike.Message {
 	response.Content = nil
 	response.Error = ""
 
-	endpoints := n.endpoints
+	endpoints := n.healthyEndpoints()
+	var rest *cmix.RestRequest
 	// Check content is not empty
-	if len(request.Content) == 0 {
-		jww.WARN.Printf("[%s %s] Got empty request", logPrefix, n.uri)
+	// REST requests can have an empty body
+	if len(request.Content) == 0 && n.adapter != cmix.AdapterRest {
+		jww.WARN.Printf("[%s] Got empty request", prefix)
 		response.Error = "Request content cannot be empty"
 		n.metrics.IncFailedEmpty()
+	} else if n.uri != "/custom" && len(endpoints) == 0 {
+		jww.WARN.Printf("[%s] No healthy endpoints", prefix)
+		code = 503
+		response.Error = "Network has no healthy endpoints"
+		n.metrics.IncFailedRpc()
+	} else if n.adapter == cmix.AdapterRest {
+		// Get REST method and path from request headers
+		var err error
+		rest, err = parseRestRequest(request.Headers)
+		if err != nil {
+			jww.WARN.Printf("[%s] %v", prefix, err)
+			response.Error = fmt.Sprintf("Invalid request: %v", err)
+			n.metrics.IncFailedInvalidRequest()
+		}
 	} else {
 		// If this is custom URI get the endpoint from request headers
 		if n.uri == "/custom" {
 			endpoint := getEndpointFromHeaders(request.Headers)
 			if endpoint == "" {
-				jww.WARN.Printf("[%s %s] Couldn't get a valid endpoint URL from request Headers: %v", logPrefix, n.uri, request.Headers)
+				jww.WARN.Printf("[%s] Couldn't get a valid endpoint URL from request Headers", prefix)
 				response.Error = "Request doesn't have a valid custom endpoint URL in request Headers"
 				n.metrics.IncFailedInvalidUrl()
 			} else {
 				// Test endpoint connection
 				if !testConnectJsonRpc(endpoint) {
-					jww.WARN.Printf("[%s %s] Couldn't connect to custom endpoint URL", logPrefix, n.uri)
+					jww.WARN.Printf("[%s] Couldn't connect to custom endpoint URL", prefix)
 					response.Error = "Provided custom endpoint URL is unreachable"
 					n.metrics.IncFailedUnreachableUrl()
 				} else {
@@ -94,18 +176,30 @@ func (n *Network) Callback(request *restlike.Message) *restlike.Message {
 	}
 
 	if response.Error == "" {
-		// Do JSON-RPC query
+		// Do query
 		var data []byte
 		var err error
-		data, code, err = doQuery(endpoints, request.Content)
+		var filterReq *cmix.FilterRequest
+		if n.adapter == cmix.AdapterJsonRpc && n.uri != "/custom" {
+			filterReq = cmix.ParseFilterRequest(request.Content)
+		}
+		if rest != nil {
+			data, code, err = restQuery(endpoints, rest, request.Content)
+		} else if filterReq != nil {
+			data, code, err = n.filterQuery(prefix, endpoints, filterReq, request.Content)
+		} else if n.adapter == cmix.AdapterJsonRpc && n.broadcast && len(endpoints) > 1 && cmix.IsWriteRequest(request.Content) {
+			data, code, err = n.broadcastQuery(prefix, endpoints, request.Content)
+		} else {
+			data, code, err = doQuery(endpoints, request.Content)
+		}
 		if err != nil {
-			errMsg := fmt.Sprintf("Error in JSON-RPC query: %v", err)
-			jww.WARN.Printf("[%s %s] %s", logPrefix, n.uri, errMsg)
+			errMsg := fmt.Sprintf("Error in %s query: %v", n.adapter, err)
+			jww.WARN.Printf("[%s] %s", prefix, errMsg)
 			response.Error = errMsg
 			n.metrics.IncFailedRpc()
 		} else {
 			response.Content = data
-			jww.INFO.Printf("[%s %s] Code (%v), Response: %v", logPrefix, n.uri, code, string(data))
+			requestLogMode.Log(prefix, fmt.Sprintf("Code (%v), Response", code), data)
 		}
 	}
 	// Place response code in headers
@@ -113,3 +207,10 @@ func (n *Network) Callback(request *restlike.Message) *restlike.Message {
 	n.metrics.IncSuccessful()
 	return response
 }
+
+// Get the endpoints that passed the last check
+func (n *Network) healthyEndpoints() []string {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.endpoints
+}
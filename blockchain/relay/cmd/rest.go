@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+	"gitlab.com/elixxir/client/v4/restlike"
+)
+
+// HTTP methods forwarded to REST endpoints
+var restMethods = map[string]struct{}{
+	http.MethodGet:  {},
+	http.MethodPost: {},
+}
+
+// Parse and validate the REST request in the request headers
+func parseRestRequest(headers *restlike.Headers) (*cmix.RestRequest, error) {
+	if headers == nil || len(headers.Headers) == 0 {
+		return nil, errors.New("missing REST request in headers")
+	}
+	var rest cmix.RestRequest
+	if err := json.Unmarshal(headers.Headers, &rest); err != nil {
+		return nil, fmt.Errorf("invalid REST request in headers: %v", err)
+	}
+	rest.Method = strings.ToUpper(rest.Method)
+	if _, ok := restMethods[rest.Method]; !ok {
+		return nil, fmt.Errorf("unsupported REST method %s", rest.Method)
+	}
+	if _, err := parseRestPath(rest.Path); err != nil {
+		return nil, err
+	}
+	return &rest, nil
+}
+
+// Parse a REST path, with an optional query
+// The path must be absolute, without host or .. segments
+func parseRestPath(restPath string) (*url.URL, error) {
+	ref, err := url.Parse(restPath)
+	if err != nil || ref.Scheme != "" || ref.Host != "" || !strings.HasPrefix(ref.Path, "/") {
+		return nil, fmt.Errorf("invalid REST path %s", restPath)
+	}
+	for _, segment := range strings.Split(ref.Path, "/") {
+		if segment == ".." {
+			return nil, fmt.Errorf("invalid REST path %s", restPath)
+		}
+	}
+	return ref, nil
+}
+
+// Build the URL of a REST request to an endpoint
+// The path is joined to the endpoint path, and the query
+// is added to the endpoint query, such as an API key
+func restUrl(base *url.URL, restPath string) (*url.URL, error) {
+	ref, err := parseRestPath(restPath)
+	if err != nil {
+		return nil, err
+	}
+	target := base.JoinPath(ref.Path)
+	switch {
+	case base.RawQuery == "":
+		target.RawQuery = ref.RawQuery
+	case ref.RawQuery != "":
+		target.RawQuery = base.RawQuery + "&" + ref.RawQuery
+	}
+	target.Fragment = ""
+	return target, nil
+}
+
+// ---------------------------- //
+// Execute a REST request to one of the endpoints, randomly selected
+// The path is joined to the endpoint URL, and can't
+// leave the endpoint path
+func restQuery(endpoints []string, rest *cmix.RestRequest, data []byte) ([]byte, int, error) {
+	endpoint := endpoints[rand.Intn(len(endpoints))]
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, 500, redactUrlError(err)
+	}
+	target, err := restUrl(base, rest.Path)
+	if err != nil {
+		return nil, 400, err
+	}
+
+	var body io.Reader
+	if len(data) > 0 {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(rest.Method, target.String(), body)
+	if err != nil {
+		err = redactUrlError(err)
+		jww.ERROR.Printf("[%s] Error creating request to query %v: %v", settings.LogPrefix, cmix.RedactURL(endpoint), err)
+		return nil, 500, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		err = redactUrlError(err)
+		jww.ERROR.Printf("[%s] Error performing request to %v: %v", settings.LogPrefix, cmix.RedactURL(endpoint), err)
+		return nil, 500, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	return respBody, resp.StatusCode, nil
+}
@@ -1,11 +1,7 @@
 package cmd
 
 import (
-	"fmt"
-	"io"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -13,182 +9,172 @@ import (
 	jww "github.com/spf13/jwalterweatherman"
 	"github.com/spf13/viper"
 	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+	"github.com/xx-labs/blockchain-cmix-relay/common/command"
 )
 
-// Cmix state config variables are global and don't change
-var statePath string
-
-// Password is a mandatory flag
-var statePassword string
+// Shared settings of cMix commands
+var settings command.Settings
 
 // Networks configuration file
 // If file is changed supported networks are reloaded
 // automatically
 var networksCfgFile string
 
-// Logging flags
-var logLevel uint // 0 = info, 1 = debug, >1 = trace
-var logPath string
-var logPrefix string
+// Logging of request and response data
+var logRequests string
+var requestLogMode cmix.LogMode
 
 // Metrics
 var metricsPort int
 
+// Interval between health checks of network endpoints
+var healthInterval time.Duration
+
+// Privacy padding
+var paddingMode string
+var paddingBlockSize int
+
 // Network manager is global because it can be reloaded
 var manager *Manager
 
 // rootCmd represents the base command when called without any sub-commands
-var rootCmd = &cobra.Command{
-	Use:   "relay",
-	Short: "Runs a blockchain cMix relay server",
-	Long:  `Relay provides a REST Server that handles client requests over cMix to query/interact with supported blockchain networks`,
-	Args:  cobra.NoArgs,
-	Run: func(cmd *cobra.Command, args []string) {
-		// Initialize logging
-		initLog()
-
-		// Config
-		config := cmix.Config{
-			LogPrefix:     logPrefix,
-			Cert:          cert,
-			NdfUrl:        ndfUrl,
-			StatePath:     statePath,
-			StatePassword: statePassword,
-		}
-
-		// Load REST server
-		server := cmix.LoadServer(config)
-
-		// Initialize networks configuration
-		networks := initNetworksConfig()
-
-		// Create network manager
-		manager = NewManager(networks, server.GetEndpoints())
-
-		// Start REST server
-		server.Start()
-
-		// Setup metrics
-		metrics := NewMetricsServer(metricsPort)
-
-		// Start metrics server
-		go metrics.Start()
-
-		// Set up channel on which to send signal notifications.
-		// We must use a buffered channel or risk missing the signal
-		// if we're not ready to receive when the signal is sent.
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+var rootCmd = command.New(command.Options{
+	Use:         "relay",
+	Short:       "Runs a blockchain cMix relay server",
+	Long:        `Relay provides a REST Server that handles client requests over cMix to query/interact with supported blockchain networks`,
+	EnvPrefix:   "RELAY",
+	LogPrefix:   "RELAY",
+	LogFile:     "relay.log",
+	ContactFile: "relay.xxc",
+	Run:         run,
+}, &settings)
+
+func run(cmd *cobra.Command, args []string) {
+	// Validate request logging mode
+	var err error
+	requestLogMode, err = cmix.ParseLogMode(logRequests)
+	if err != nil {
+		jww.FATAL.Panicf("[%s] %v", settings.LogPrefix, err)
+	}
 
-		// Block to prevent the program ending until a signal is received
-		<-c
+	// Load REST server
+	server := cmix.LoadServer(settings.CmixConfig())
 
-		// Stop REST server
-		server.Stop()
+	// Validate padding mode
+	padding, err := cmix.ParsePaddingMode(paddingMode)
+	if err != nil {
+		jww.FATAL.Panicf("[%s] %v", settings.LogPrefix, err)
+	}
 
-		// Stop metrics server
-		metrics.Stop()
-	},
+	// Initialize networks configuration
+	networks := initNetworksConfig()
+
+	// Create network manager
+	manager = NewManager(networks, server.GetEndpoints(), cmix.Padding{
+		Mode:      padding,
+		BlockSize: paddingBlockSize,
+	}, healthInterval)
+
+	// Setup metrics
+	metrics := NewMetricsServer(metricsPort)
+
+	// Run REST and metrics servers until a signal is received
+	settings.Serve(
+		command.Component{
+			Name: "network health checks",
+			Stop: func() { manager.Stop() },
+		},
+		command.Component{
+			Name:  "REST server",
+			Start: server.Start,
+			Stop:  server.Stop,
+		},
+		command.Component{
+			Name:  "metrics server",
+			Start: func() { go metrics.Start() },
+			Stop:  metrics.Stop,
+		},
+	)
 }
 
 // Execute adds all child commands to the root command and sets flags
 // appropriately.  This is called by main.main(). It only needs to
 // happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		jww.ERROR.Printf("[%s] Server exiting with error: %s", logPrefix, err.Error())
-		os.Exit(1)
-	}
-	jww.INFO.Printf("[%s] Server exiting without error...", logPrefix)
+	command.Execute(rootCmd, &settings)
 }
 
 // init is the initialization function for Cobra which defines commands
 // and flags.
 func init() {
-	// Set flags
+	// Announcement
+	rootCmd.AddCommand(newAnnounceCommand())
 
-	// cMix state
-	rootCmd.PersistentFlags().StringVarP(&statePath, "statePath", "s", "state", "Path cMix state directory")
-	rootCmd.PersistentFlags().StringVarP(&statePassword, "statePassword", "p", "", "Password for cMix state")
-	rootCmd.MarkPersistentFlagRequired("statePassword")
+	// Set flags
 
 	// Networks configuration file
 	rootCmd.Flags().StringVarP(&networksCfgFile, "networks", "n", "networks.json", "Path to networks configuration file")
+	rootCmd.Flags().DurationVarP(&healthInterval, "healthInterval", "", 5*time.Minute, "Interval between checks that network endpoints are reachable and serve the expected chain ID or genesis hash (0 = check only on startup and reload)")
 
 	// Logging
-	rootCmd.PersistentFlags().UintVarP(&logLevel, "logLevel", "l", 0, "Level of debugging to print (0 = info, 1 = debug, >1 = trace).")
-	rootCmd.PersistentFlags().StringVarP(&logPath, "logFile", "f", "relay.log", "Path to log file")
-	rootCmd.Flags().StringVarP(&logPrefix, "logPrefix", "", "RELAY", "Logging prefix")
+	rootCmd.Flags().StringVarP(&logRequests, "logRequests", "", string(cmix.LogMetadata), "Logging of requests and responses (full = bodies logged at trace level, metadata = sizes only, none)")
 
 	// Metrics
 	rootCmd.PersistentFlags().IntVarP(&metricsPort, "metricsPort", "m", 9296, "Port for metrics server")
-}
 
-// initLog initializes logging thresholds and the log path.
-func initLog() {
-	// Check the level of logs to display
-	if logLevel > 1 {
-		// Turn on trace logs
-		jww.SetLogThreshold(jww.LevelTrace)
-	} else if logLevel == 1 {
-		// Turn on debugging logs
-		jww.SetLogThreshold(jww.LevelDebug)
-	} else {
-		// Turn on info logs
-		jww.SetLogThreshold(jww.LevelInfo)
-	}
-
-	// Create log file, overwrites if existing
-	logFile, err := os.Create(logPath)
-	if err != nil {
-		fmt.Printf("[%s] Could not open log file %s!\n", logPrefix, logPath)
-	} else {
-		jww.SetLogOutput(logFile)
-		jww.SetStdoutOutput(io.Discard)
-	}
+	// Padding
+	rootCmd.Flags().StringVarP(&paddingMode, "padding", "", string(cmix.PaddingBuckets), "Padding of responses to padded requests, to hide their size (none, buckets, block)")
+	rootCmd.Flags().IntVarP(&paddingBlockSize, "paddingBlockSize", "", cmix.DefaultBlockSize, "Block size for block padding, responses are padded to a multiple of this size")
 }
 
 // Don't allow repeated reloads
 var reloadDelay = 5 * time.Second
 var reloaded = false
 
-// initNetworksConfig reads in the networks config file
-func initNetworksConfig() map[string][]NetworkConfig {
+// readNetworksConfig reads in the networks config file
+func readNetworksConfig() map[string][]NetworkConfig {
 	// Panic if no networks configuration file is set
 	if networksCfgFile == "" {
-		jww.FATAL.Panicf("[%s] No networks config file provided.", logPrefix)
+		jww.FATAL.Panicf("[%s] No networks config file provided.", settings.LogPrefix)
 	}
 
 	// Panic if configuration file is not available
 	f, err := os.Open(networksCfgFile)
 	if err != nil {
-		jww.FATAL.Panicf("[%s] Could not open config file: %+v", logPrefix, err)
+		jww.FATAL.Panicf("[%s] Could not open config file: %+v", settings.LogPrefix, err)
 	}
 	err = f.Close()
 	if err != nil {
-		jww.FATAL.Panicf("[%s] Could not close config file: %+v", logPrefix, err)
+		jww.FATAL.Panicf("[%s] Could not close config file: %+v", settings.LogPrefix, err)
 	}
 
 	// Read config file using viper
 	viper.SetConfigFile(networksCfgFile)
 	viper.SetConfigType("json")
 	if err = viper.ReadInConfig(); err != nil {
-		jww.FATAL.Panicf("[%s] Unable to read networks config file (%s): %s", logPrefix, networksCfgFile, err.Error())
+		jww.FATAL.Panicf("[%s] Unable to read networks config file (%s): %s", settings.LogPrefix, networksCfgFile, err.Error())
 	}
 	var networks map[string][]NetworkConfig
 	if err = viper.Unmarshal(&networks); err != nil {
-		jww.FATAL.Panicf("[%s] Unable to unmarshall networks JSON: %s", logPrefix, err.Error())
+		jww.FATAL.Panicf("[%s] Unable to unmarshall networks JSON: %s", settings.LogPrefix, err.Error())
 	}
+	return networks
+}
+
+// initNetworksConfig reads in the networks config file
+// and watches it for changes
+func initNetworksConfig() map[string][]NetworkConfig {
+	networks := readNetworksConfig()
 
 	// Setup networks config reloading
 	viper.OnConfigChange(func(e fsnotify.Event) {
 		if e.Op == fsnotify.Write && !reloaded {
-			jww.INFO.Printf("[%s] Reloading networks configuration", logPrefix)
+			jww.INFO.Printf("[%s] Reloading networks configuration", settings.LogPrefix)
 			var newNetworks map[string][]NetworkConfig
-			if err = viper.Unmarshal(&newNetworks); err != nil {
-				jww.ERROR.Printf("[%s] Unable to unmarshall new networks configuration JSON: %s", logPrefix, err.Error())
+			if err := viper.Unmarshal(&newNetworks); err != nil {
+				jww.ERROR.Printf("[%s] Unable to unmarshall new networks configuration JSON: %s", settings.LogPrefix, err.Error())
 			} else {
-				jww.INFO.Printf("[%s] Reloading network manager", logPrefix)
+				jww.INFO.Printf("[%s] Reloading network manager", settings.LogPrefix)
 				manager.Reload(newNetworks)
 				reloaded = true
 				// Clear reloaded flag after the delay
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"math/rand"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
 	"gitlab.com/elixxir/client/v4/restlike"
 )
 
@@ -35,7 +37,7 @@ func testConnectJsonRpc(url string) bool {
 		valid = false
 	} else {
 		if code != 200 && code != 400 && code != 403 && code != 404 {
-			jww.INFO.Printf("[%s] Endpoint %v returned code %v", logPrefix, url, code)
+			jww.INFO.Printf("[%s] Endpoint %v returned code %v", settings.LogPrefix, cmix.RedactURL(url), code)
 			valid = false
 		}
 	}
@@ -43,10 +45,12 @@ func testConnectJsonRpc(url string) bool {
 }
 
 // Perform HTTP POST request with JSON-RPC format
-func queryJsonRpc(url string, data []byte) ([]byte, int, error) {
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+// Errors don't contain the endpoint URL, since they are sent to clients
+func queryJsonRpc(endpoint string, data []byte) ([]byte, int, error) {
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(data))
 	if err != nil {
-		jww.ERROR.Printf("[%s] Error creating request to query %v: %v", logPrefix, url, err)
+		err = redactUrlError(err)
+		jww.ERROR.Printf("[%s] Error creating request to query %v: %v", settings.LogPrefix, cmix.RedactURL(endpoint), err)
 		return nil, 500, err
 	}
 	req.Header.Set("Content-Type", "application/json")
@@ -54,7 +58,8 @@ func queryJsonRpc(url string, data []byte) ([]byte, int, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		jww.ERROR.Printf("[%s] Error performing request to %v: %v", logPrefix, url, err)
+		err = redactUrlError(err)
+		jww.ERROR.Printf("[%s] Error performing request to %v: %v", settings.LogPrefix, cmix.RedactURL(endpoint), err)
 		return nil, 500, err
 	}
 	defer resp.Body.Close()
@@ -62,11 +67,20 @@ func queryJsonRpc(url string, data []byte) ([]byte, int, error) {
 	return body, resp.StatusCode, nil
 }
 
+// Redact the URL from an HTTP request error
+func redactUrlError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = cmix.RedactURL(urlErr.URL)
+	}
+	return err
+}
+
 // Extract the RPC endpoint from the request headers
 func getEndpointFromHeaders(headers *restlike.Headers) string {
 	// 1. Check if headers are empty
 	if headers == nil || len(headers.Headers) == 0 {
-		jww.INFO.Printf("[%s] Empty headers in custom URI request", logPrefix)
+		jww.INFO.Printf("[%s] Empty headers in custom URI request", settings.LogPrefix)
 		return ""
 	}
 
@@ -83,11 +97,11 @@ func getEndpointFromHeaders(headers *restlike.Headers) string {
 func isValidHTTPSURL(input string) bool {
 	u, err := url.Parse(input)
 	if err != nil {
-		jww.INFO.Printf("[%s] Couldn't parse URL from headers", logPrefix)
+		jww.INFO.Printf("[%s] Couldn't parse URL from headers", settings.LogPrefix)
 		return false
 	}
 	if u.Scheme != "https" {
-		jww.INFO.Printf("[%s] URL is not HTTPS", logPrefix)
+		jww.INFO.Printf("[%s] URL is not HTTPS", settings.LogPrefix)
 		return false
 	}
 	return true
@@ -4,11 +4,13 @@ go 1.19
 
 require (
 	github.com/fsnotify/fsnotify v1.5.4
-	github.com/spf13/cobra v1.5.0
+	github.com/prometheus/client_golang v1.15.1
+	github.com/spf13/cobra v1.7.0
 	github.com/spf13/jwalterweatherman v1.1.0
 	github.com/spf13/viper v1.12.0
-	gitlab.com/elixxir/client/v4 v4.6.2-0.20230407173222-f2352c0ca7e4
-	gitlab.com/xx_network/primitives v0.0.4-0.20230310205521-c440e68e34c4
+	github.com/xx-labs/blockchain-cmix-relay/cmix v0.0.0-00010101000000-000000000000
+	github.com/xx-labs/blockchain-cmix-relay/common v0.0.0-00010101000000-000000000000
+	gitlab.com/elixxir/client/v4 v4.6.3
 )
 
 require (
@@ -26,7 +28,7 @@ require (
 	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/improbable-eng/grpc-web v0.15.0 // indirect
-	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/klauspost/compress v1.15.9 // indirect
 	github.com/klauspost/cpuid/v2 v2.1.0 // indirect
 	github.com/magiconair/properties v1.8.6 // indirect
@@ -37,7 +39,6 @@ require (
 	github.com/pelletier/go-toml v1.9.5 // indirect
 	github.com/pelletier/go-toml/v2 v2.0.2 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/prometheus/client_golang v1.15.1 // indirect
 	github.com/prometheus/client_model v0.3.0 // indirect
 	github.com/prometheus/common v0.42.0 // indirect
 	github.com/prometheus/procfs v0.9.0 // indirect
@@ -54,16 +55,18 @@ require (
 	github.com/zeebo/blake3 v0.2.3 // indirect
 	gitlab.com/elixxir/bloomfilter v0.0.0-20230315224936-a4459418f300 // indirect
 	gitlab.com/elixxir/comms v0.0.4-0.20230310205528-f06faa0d2f0b // indirect
-	gitlab.com/elixxir/crypto v0.0.7-0.20230322175717-4a3b5a24bdf4 // indirect
+	gitlab.com/elixxir/crypto v0.0.7-0.20230413162806-a99ec4bfea32 // indirect
 	gitlab.com/elixxir/ekv v0.2.1 // indirect
 	gitlab.com/elixxir/primitives v0.0.3-0.20230214180039-9a25e2d3969c // indirect
 	gitlab.com/xx_network/comms v0.0.4-0.20230214180029-5387fb85736d // indirect
 	gitlab.com/xx_network/crypto v0.0.5-0.20230214003943-8a09396e95dd // indirect
+	gitlab.com/xx_network/primitives v0.0.4-0.20230310205521-c440e68e34c4 // indirect
 	gitlab.com/xx_network/ring v0.0.3-0.20220902183151-a7d3b15bc981 // indirect
 	go.uber.org/atomic v1.10.0 // indirect
 	golang.org/x/crypto v0.5.0 // indirect
 	golang.org/x/net v0.7.0 // indirect
 	golang.org/x/sys v0.6.0 // indirect
+	golang.org/x/term v0.5.0 // indirect
 	golang.org/x/text v0.7.0 // indirect
 	google.golang.org/genproto v0.0.0-20220822174746-9e6da59bd2fc // indirect
 	google.golang.org/grpc v1.49.0 // indirect
@@ -74,3 +77,8 @@ require (
 	nhooyr.io/websocket v1.8.7 // indirect
 	src.agwa.name/tlshacks v0.0.0-20220518131152-d2c6f4e2b780 // indirect
 )
+
+replace (
+	github.com/xx-labs/blockchain-cmix-relay/cmix => ../../cmix
+	github.com/xx-labs/blockchain-cmix-relay/common => ../../common
+)
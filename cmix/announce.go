@@ -0,0 +1,228 @@
+package cmix
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/client/v4/storage/versioned"
+	"gitlab.com/elixxir/client/v4/xxdk"
+	"gitlab.com/elixxir/crypto/contact"
+	"gitlab.com/elixxir/ekv"
+)
+
+// ---------------------------- //
+// Relay server announcement
+//
+// An announcement is a statement by a relay server operator about
+// their relay server, signed with an ed25519 key stored in the
+// cMix state of the server
+// Clients that pin the public key of the operator can verify
+// that a contact file really belongs to the operator's relay server
+//
+// Signed announcement format:
+//
+//	{
+//	  "announcement": {
+//	    "version": 1,
+//	    "contact": "<base64 marshalled contact>",
+//	    "networks": ["/ethereum/mainnet"],
+//	    "operator": {"name": "xx labs", "url": "https://xx.network"},
+//	    "issued": "2023-05-01T00:00:00Z",
+//	    "expires": "2023-06-01T00:00:00Z",
+//	    "key": "<base64 ed25519 public key>"
+//	  },
+//	  "signature": "<base64 ed25519 signature of the announcement value>"
+//	}
+//
+// The signature covers the compact JSON encoding of the announcement value
+
+// Version of the announcement format
+const AnnouncementVersion = 1
+
+// Storage key of the announcement signing key in the cMix state
+const announceKeyStorageKey = "announceKeyStorageKey"
+
+// Announcement is a statement about a relay server
+type Announcement struct {
+	// Version of the announcement format
+	Version int `json:"version"`
+
+	// Marshalled cMix contact of the relay server
+	Contact []byte `json:"contact"`
+
+	// Networks supported by the relay server
+	Networks []string `json:"networks"`
+
+	// Operator of the relay server
+	Operator Operator `json:"operator"`
+
+	// Time the announcement was issued
+	Issued time.Time `json:"issued"`
+
+	// Time after which the announcement is no longer valid
+	// The announcement doesn't expire if zero
+	Expires time.Time `json:"expires,omitempty"`
+
+	// Public key the announcement is signed with
+	Key ed25519.PublicKey `json:"key"`
+}
+
+// Operator of a relay server
+type Operator struct {
+	Name    string `json:"name"`
+	Url     string `json:"url,omitempty"`
+	Contact string `json:"contact,omitempty"`
+}
+
+// SignedAnnouncement is an announcement with its signature
+type SignedAnnouncement struct {
+	Announcement json.RawMessage `json:"announcement"`
+	Signature    []byte          `json:"signature"`
+}
+
+// Errors of announcement verification
+var (
+	ErrInvalidAnnouncementSignature = errors.New("invalid announcement signature")
+	ErrAnnouncementExpired          = errors.New("announcement expired")
+)
+
+// ---------------------------- //
+// Announce a cMix Server
+// This function loads the state from the configured path,
+// and writes an announcement of the server, with its contact
+// information, signed with the announcement key stored in the state,
+// to the provided filepath
+// The announcement key is created on first use
+// Returns the announcement public key
+func AnnounceServer(c Config, a Announcement, outputFile string) ed25519.PublicKey {
+	// Load Server
+	net, identity := newServer(c, false)
+
+	// Load or create the announcement key
+	key, err := loadAnnounceKey(net)
+	if err != nil {
+		jww.FATAL.Panicf("[%s] %+v", c.LogPrefix, err)
+	}
+
+	// Sign announcement
+	a.Version = AnnouncementVersion
+	a.Contact = identity.GetContact().Marshal()
+	a.Key = key.Public().(ed25519.PublicKey)
+	data, err := SignAnnouncement(&a, key)
+	if err != nil {
+		jww.FATAL.Panicf("[%s] Failed to sign announcement: %+v", c.LogPrefix, err)
+	}
+
+	// Save the announcement at the provided filepath
+	err = os.WriteFile(outputFile, append(data, '\n'), 0644)
+	if err != nil {
+		jww.FATAL.Panicf("[%s] Failed writing announcement to %v: %+v", c.LogPrefix, outputFile, err)
+	}
+	jww.INFO.Printf("[%s] Wrote announcement to %v", c.LogPrefix, outputFile)
+	return a.Key
+}
+
+// ---------------------------- //
+// Sign an announcement with the given private key
+// Returns the signed announcement document
+func SignAnnouncement(a *Announcement, key ed25519.PrivateKey) ([]byte, error) {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(SignedAnnouncement{
+		Announcement: data,
+		Signature:    ed25519.Sign(key, data),
+	}, "", "  ")
+}
+
+// ---------------------------- //
+// Verify a signed announcement document
+// Returns the announcement if it is signed by its key,
+// the format is supported, it isn't expired and
+// the contact is valid
+// The caller must check that the key is trusted
+func VerifyAnnouncement(data []byte) (*Announcement, error) {
+	var signed SignedAnnouncement
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, fmt.Errorf("invalid signed announcement: %v", err)
+	}
+	var a Announcement
+	if err := json.Unmarshal(signed.Announcement, &a); err != nil {
+		return nil, fmt.Errorf("invalid announcement: %v", err)
+	}
+	if len(a.Key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid announcement key size %d", len(a.Key))
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, signed.Announcement); err != nil {
+		return nil, fmt.Errorf("invalid announcement: %v", err)
+	}
+	if !ed25519.Verify(a.Key, compact.Bytes(), signed.Signature) {
+		return nil, ErrInvalidAnnouncementSignature
+	}
+	if a.Version != AnnouncementVersion {
+		return nil, fmt.Errorf("unsupported announcement version %d", a.Version)
+	}
+	if !a.Expires.IsZero() && time.Now().After(a.Expires) {
+		return nil, fmt.Errorf("%w at %v", ErrAnnouncementExpired, a.Expires)
+	}
+	if _, err := a.ParseContact(); err != nil {
+		return nil, fmt.Errorf("invalid announced contact: %v", err)
+	}
+	return &a, nil
+}
+
+// ---------------------------- //
+// Parse the announced cMix contact
+func (a *Announcement) ParseContact() (contact.Contact, error) {
+	return contact.Unmarshal(a.Contact)
+}
+
+// ---------------------------- //
+// Return the base64 encoded announcement key
+func (a *Announcement) KeyString() string {
+	return base64.StdEncoding.EncodeToString(a.Key)
+}
+
+// ---------------------------- //
+// Internal functions
+// ---------------------------- //
+
+// Load the announcement key from the cMix state,
+// creating and storing a new one if it doesn't exist
+func loadAnnounceKey(net *xxdk.Cmix) (ed25519.PrivateKey, error) {
+	obj, err := net.GetStorage().Get(announceKeyStorageKey)
+	if err == nil {
+		if len(obj.Data) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("invalid stored announcement key size %d", len(obj.Data))
+		}
+		return ed25519.PrivateKey(obj.Data), nil
+	}
+	if ekv.Exists(err) {
+		return nil, fmt.Errorf("failed to load announcement key: %w", err)
+	}
+
+	// Create a new key
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate announcement key: %w", err)
+	}
+	err = net.GetStorage().Set(announceKeyStorageKey, &versioned.Object{
+		Version:   0,
+		Timestamp: time.Now(),
+		Data:      key,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store announcement key: %w", err)
+	}
+	return key, nil
+}
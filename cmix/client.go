@@ -2,8 +2,11 @@ package cmix
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	jww "github.com/spf13/jwalterweatherman"
@@ -18,58 +21,321 @@ import (
 
 // ---------------------------- //
 // Client holds the xxDK user info
+// The cMix session is replaced when the identity is rotated
 type Client struct {
-	user      *xxdk.E2e
-	stream    *fastRNG.Stream
-	grp       *cyclic.Group
+	config    Config
+	ndf       []byte
+	session   *clientSession
 	logPrefix string
+	requests  RequestTracker
+
+	mux         sync.RWMutex
+	rotating    bool
+	lastFailure time.Time
+	stopped     bool
+	stopChan    chan struct{}
+	wg          sync.WaitGroup
+}
+
+// clientSession holds the cMix state of a single identity
+type clientSession struct {
+	user   *xxdk.E2e
+	stream *fastRNG.Stream
+	grp    *cyclic.Group
+
+	// Temporary directory of an ephemeral state
+	// Empty for the persistent state
+	dir string
+
+	requests atomic.Uint64
+	inFlight sync.WaitGroup
 }
 
+// Delay before retrying a failed identity rotation
+const rotateRetryDelay = 30 * time.Second
+
 // ---------------------------- //
 // Create a new cMix client
 func NewClient(c Config) *Client {
-	// Initialize xxDK state
-	// If state already exists, re-use it
-	if _, err := os.Stat(c.StatePath); errors.Is(err, fs.ErrNotExist) {
-		jww.INFO.Printf("[%s] Initializing state at %v", c.LogPrefix, c.StatePath)
-		// Retrieve NDF
-		cert, err := os.ReadFile(c.Cert)
+	client := &Client{
+		config:    c,
+		logPrefix: c.LogPrefix,
+	}
+
+	var err error
+	if c.Identity.Ephemeral() {
+		jww.INFO.Printf("[%s] Creating %s identity", c.LogPrefix, c.Identity.Policy)
+		client.session, err = client.newEphemeralSession()
+	} else {
+		client.session, err = client.newPersistentSession()
+	}
+	if err != nil {
+		jww.FATAL.Panicf("[%s] %+v", c.LogPrefix, err)
+	}
+	return client
+}
+
+// ---------------------------- //
+// Wipe the client state at the configured path
+// and initialize a new state with a new persistent identity
+func ResetClient(c Config) {
+	if _, err := os.Stat(c.StatePath); err == nil {
+		jww.INFO.Printf("[%s] Removing existing state at %v", c.LogPrefix, c.StatePath)
+		err = os.RemoveAll(c.StatePath)
+		if err != nil {
+			jww.FATAL.Panicf("[%s] Error removing existing state at %v: %+v", c.LogPrefix, c.StatePath, err)
+		}
+	}
+	client := &Client{
+		config:    c,
+		logPrefix: c.LogPrefix,
+	}
+	s, err := client.newPersistentSession()
+	if err != nil {
+		jww.FATAL.Panicf("[%s] %+v", c.LogPrefix, err)
+	}
+	s.stream.Close()
+	jww.INFO.Printf("[%s] Created new identity %s", c.LogPrefix, s.user.GetReceptionIdentity().ID)
+}
+
+// ---------------------------- //
+// Start the Client
+// This function starts the cMix network follower
+// then waits until the Client is connected to the network
+func (c *Client) Start() {
+	err := c.session.start()
+	if err != nil {
+		jww.FATAL.Panicf("[%s] %+v", c.logPrefix, err)
+	}
+	jww.INFO.Printf("[%s] Started cMix Client", c.logPrefix)
+
+	// Rotate the identity periodically
+	if c.config.Identity.Rotates() && c.config.Identity.RotateInterval > 0 {
+		c.stopChan = make(chan struct{})
+		c.wg.Add(1)
+		go c.rotateEvery(c.config.Identity.RotateInterval)
+	}
+}
+
+// ---------------------------- //
+// Stop the Client
+// New requests are rejected, and requests in progress are
+// given the drain timeout to finish before stopping
+func (c *Client) Stop() {
+	// Drain requests
+	if dropped := c.requests.Drain(c.config.DrainTimeout); dropped > 0 {
+		jww.WARN.Printf("[%s] Dropped %d cMix requests in progress after %v", c.logPrefix, dropped, c.config.DrainTimeout)
+	}
+
+	c.mux.Lock()
+	c.stopped = true
+	s := c.session
+	c.mux.Unlock()
+
+	// Wait for identity rotation to finish
+	if c.stopChan != nil {
+		close(c.stopChan)
+	}
+	c.wg.Wait()
+
+	if s != nil {
+		s.stop(c.logPrefix)
+	}
+	jww.INFO.Printf("[%s] Stopped cMix Client", c.logPrefix)
+}
+
+// Error returned for requests to a Client without a session
+var errNoSession = errors.New("cMix client has no session")
+
+type Request struct {
+	Method  restlike.Method
+	Uri     string
+	Data    []byte
+	Headers []byte
+}
+
+// ---------------------------- //
+// Send a single-use REST request to a given contact
+// Requests are rejected once the Client is stopping,
+// or if it has no session, like a zero Client
+func (c *Client) Request(name string, contact contact.Contact, req Request) (*restlike.Message, error) {
+	if !c.requests.Begin() {
+		return nil, fmt.Errorf("cMix client is %w", ErrShuttingDown)
+	}
+	defer c.requests.End()
+
+	s := c.acquire()
+	if s == nil {
+		return nil, errNoSession
+	}
+	defer s.inFlight.Done()
+
+	// Build request
+	request := restSingle.Request{
+		Net:    s.user.GetCmix(),
+		Rng:    s.stream,
+		E2eGrp: s.grp,
+	}
+
+	// Send request and wait for response
+	jww.INFO.Printf("[%s] Sending request over cMix to %s", c.logPrefix, name)
+	response, err := request.Request(contact,
+		req.Method, restlike.URI(req.Uri), req.Data, &restlike.Headers{Headers: req.Headers},
+		single.GetDefaultRequestParams(),
+	)
+	if err != nil {
+		jww.ERROR.Printf("[%s] Failed to send request over cMix: %+v", c.logPrefix, err)
+		return nil, err
+	}
+	return response, nil
+}
+
+// ---------------------------- //
+// Internal functions
+// ---------------------------- //
+
+// Get the current session for sending a request
+// Triggers identity rotation if the session reached the request limit
+// The caller must mark the request as done in the session
+func (c *Client) acquire() *clientSession {
+	c.mux.RLock()
+	s := c.session
+	if s == nil {
+		c.mux.RUnlock()
+		return nil
+	}
+	s.inFlight.Add(1)
+	requests := s.requests.Add(1)
+	c.mux.RUnlock()
+
+	limit := c.config.Identity.RotateRequests
+	if c.config.Identity.Rotates() && limit > 0 && requests >= limit {
+		c.triggerRotate()
+	}
+	return s
+}
+
+// Rotate the identity at the given interval until stopped
+func (c *Client) rotateEvery(interval time.Duration) {
+	defer c.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			c.triggerRotate()
+		}
+	}
+}
+
+// Start rotating the identity in the background,
+// unless a rotation is already in progress
+func (c *Client) triggerRotate() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.rotating || c.stopped || time.Since(c.lastFailure) < rotateRetryDelay {
+		return
+	}
+	c.rotating = true
+	c.wg.Add(1)
+	go c.rotate()
+}
+
+// Create and start a session with a new identity, then replace
+// the current session once it is connected to the network
+// The old session is stopped after its in-flight requests finish
+func (c *Client) rotate() {
+	defer c.wg.Done()
+	jww.INFO.Printf("[%s] Rotating identity", c.logPrefix)
+	s, err := c.newEphemeralSession()
+	if err == nil {
+		err = s.start()
 		if err != nil {
-			jww.FATAL.Panicf("[%s] Failed to read certificate: %v", c.LogPrefix, err)
+			s.stop(c.logPrefix)
 		}
+	}
+	if err != nil {
+		jww.ERROR.Printf("[%s] Failed to rotate identity: %+v", c.logPrefix, err)
+		c.mux.Lock()
+		c.rotating = false
+		c.lastFailure = time.Now()
+		c.mux.Unlock()
+		return
+	}
+
+	c.mux.Lock()
+	if c.stopped {
+		c.mux.Unlock()
+		s.stop(c.logPrefix)
+		return
+	}
+	old := c.session
+	c.session = s
+	c.rotating = false
+	c.mux.Unlock()
+	jww.INFO.Printf("[%s] Rotated identity to %s", c.logPrefix, s.user.GetReceptionIdentity().ID)
+
+	old.inFlight.Wait()
+	old.stop(c.logPrefix)
+}
+
+// Download the NDF, or return the previously downloaded one
+func (c *Client) getNdf() ([]byte, error) {
+	if c.ndf != nil {
+		return c.ndf, nil
+	}
+	cert, err := os.ReadFile(c.config.Cert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %v", err)
+	}
+	ndfJSON, err := xxdk.DownloadAndVerifySignedNdfWithUrl(c.config.NdfUrl, string(cert))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download NDF: %+v", err)
+	}
+	c.ndf = ndfJSON
+	return ndfJSON, nil
+}
 
-		ndfJSON, err := xxdk.DownloadAndVerifySignedNdfWithUrl(c.NdfUrl, string(cert))
+// Load the session from the configured state path
+// If state doesn't exist, it is initialized with a new identity
+func (c *Client) newPersistentSession() (*clientSession, error) {
+	// Initialize xxDK state
+	// If state already exists, re-use it
+	if _, err := os.Stat(c.config.StatePath); errors.Is(err, fs.ErrNotExist) {
+		jww.INFO.Printf("[%s] Initializing state at %v", c.logPrefix, c.config.StatePath)
+		ndfJSON, err := c.getNdf()
 		if err != nil {
-			jww.FATAL.Panicf("[%s] Failed to download NDF: %+v", c.LogPrefix, err)
+			return nil, err
 		}
 
 		// Initialize the state using the state file
-		err = xxdk.NewCmix(string(ndfJSON), c.StatePath, []byte(c.StatePassword), "")
+		err = xxdk.NewCmix(string(ndfJSON), c.config.StatePath, []byte(c.config.StatePassword), "")
 		if err != nil {
-			jww.FATAL.Panicf("[%s] Failed to initialize state: %+v", c.LogPrefix, err)
+			return nil, fmt.Errorf("failed to initialize state: %+v", err)
 		}
 	}
 
 	// Load cMix
-	jww.INFO.Printf("[%s] Loading state at %v", c.LogPrefix, c.StatePath)
-	net, err := xxdk.LoadCmix(c.StatePath, []byte(c.StatePassword),
+	jww.INFO.Printf("[%s] Loading state at %v", c.logPrefix, c.config.StatePath)
+	net, err := xxdk.LoadCmix(c.config.StatePath, []byte(c.config.StatePassword),
 		xxdk.GetDefaultCMixParams())
 	if err != nil {
-		jww.FATAL.Panicf("[%s] Failed to load state: %+v", c.LogPrefix, err)
+		return nil, fmt.Errorf("failed to load state: %+v", err)
 	}
 
 	// Get reception identity (automatically created if one does not exist)
-	identityStorageKey := "identityStorageKey"
 	identity, err := xxdk.LoadReceptionIdentity(identityStorageKey, net)
 	if err != nil {
 		// If no extant xxdk.ReceptionIdentity, generate and store a new one
 		identity, err = xxdk.MakeReceptionIdentity(net)
 		if err != nil {
-			jww.FATAL.Panicf("[%s] Failed to generate reception identity: %+v", c.LogPrefix, err)
+			return nil, fmt.Errorf("failed to generate reception identity: %+v", err)
 		}
 		err = xxdk.StoreReceptionIdentity(identityStorageKey, identity, net)
 		if err != nil {
-			jww.FATAL.Panicf("[%s] Failed to store new reception identity: %+v", c.LogPrefix, err)
+			return nil, fmt.Errorf("failed to store new reception identity: %+v", err)
 		}
 	}
 
@@ -77,104 +343,132 @@ func NewClient(c Config) *Client {
 	params := xxdk.GetDefaultE2EParams()
 	user, err := xxdk.Login(net, xxdk.DefaultAuthCallbacks{}, identity, params)
 	if err != nil {
-		jww.FATAL.Panicf("[%s] Unable to Login: %+v", c.LogPrefix, err)
+		return nil, fmt.Errorf("unable to Login: %+v", err)
+	}
+	return newClientSession(user, identity, "")
+}
+
+// Create a session with a new identity in a temporary state
+// The state is removed when the session is stopped
+func (c *Client) newEphemeralSession() (*clientSession, error) {
+	ndfJSON, err := c.getNdf()
+	if err != nil {
+		return nil, err
+	}
+	dir, statePath, err := ephemeralStatePath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary state directory: %v", err)
+	}
+	s, err := newEphemeralState(ndfJSON, dir, statePath)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return s, nil
+}
+
+func newEphemeralState(ndfJSON []byte, dir, statePath string) (*clientSession, error) {
+	password, err := ephemeralPassword()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate state password: %v", err)
+	}
+
+	// Initialize and load cMix
+	err = xxdk.NewCmix(string(ndfJSON), statePath, []byte(password), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize state: %+v", err)
+	}
+	net, err := xxdk.LoadCmix(statePath, []byte(password), xxdk.GetDefaultCMixParams())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %+v", err)
+	}
+
+	// Generate a reception identity, which is never stored
+	identity, err := xxdk.MakeReceptionIdentity(net)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate reception identity: %+v", err)
 	}
 
-	// Start a stream
-	stream := user.GetRng().GetStream()
+	// Create an E2E client with ephemeral storage
+	params := xxdk.GetDefaultE2EParams()
+	user, err := xxdk.LoginEphemeral(net, xxdk.DefaultAuthCallbacks{}, identity, params)
+	if err != nil {
+		return nil, fmt.Errorf("unable to Login: %+v", err)
+	}
+	return newClientSession(user, identity, dir)
+}
 
+func newClientSession(user *xxdk.E2e, identity xxdk.ReceptionIdentity, dir string) (*clientSession, error) {
 	// Get the group
 	grp, err := identity.GetGroup()
 	if err != nil {
-		jww.FATAL.Panicf("[%s] Failed to get group from identity: %+v", c.LogPrefix, err)
+		return nil, fmt.Errorf("failed to get group from identity: %+v", err)
 	}
 
-	// Create Client
-	return &Client{
-		user:      user,
-		stream:    stream,
-		grp:       grp,
-		logPrefix: c.LogPrefix,
-	}
+	return &clientSession{
+		user:   user,
+		stream: user.GetRng().GetStream(),
+		grp:    grp,
+		dir:    dir,
+	}, nil
 }
 
-// ---------------------------- //
-// Start the Client
-// This function starts the cMix network follower
-// then waits until the Client is connected to the network
-func (c *Client) Start() {
+// Start the cMix network follower
+// then wait until the session is connected to the network
+func (s *clientSession) start() error {
 	// Start cMix network follower
 	networkFollowerTimeout := 5 * time.Second
-	err := c.user.StartNetworkFollower(networkFollowerTimeout)
+	err := s.user.StartNetworkFollower(networkFollowerTimeout)
 	if err != nil {
-		jww.FATAL.Panicf("[%s] Failed to start cMix network follower: %+v", c.logPrefix, err)
+		return fmt.Errorf("failed to start cMix network follower: %+v", err)
 	}
 
 	// Create a tracker channel to be notified of network changes
 	connected := make(chan bool, 10)
 	// Provide a callback that will be signalled when network
 	// health status changes
-	c.user.GetCmix().AddHealthCallback(
+	callbackId := s.user.GetCmix().AddHealthCallback(
 		func(isConnected bool) {
-			connected <- isConnected
+			select {
+			case connected <- isConnected:
+			default:
+			}
 		})
+	defer s.user.GetCmix().RemoveHealthCallback(callbackId)
 
-	// Wait until connected or crash on timeout
+	// Wait until connected or fail on timeout
 	waitTimeout := 30 * time.Second
 	timeoutTimer := time.NewTimer(waitTimeout)
+	defer timeoutTimer.Stop()
 	isConnected := false
 	for !isConnected {
 		select {
 		case isConnected = <-connected:
 		case <-timeoutTimer.C:
-			jww.FATAL.Panicf("[%s] Timeout on starting cMix Client", c.logPrefix)
+			return errors.New("timeout on starting cMix Client")
 		}
 	}
-	jww.INFO.Printf("[%s] Started cMix Client", c.logPrefix)
+	return nil
 }
 
-// ---------------------------- //
-// Stop the Client
-func (c *Client) Stop() {
+// Stop the cMix network follower and remove ephemeral state
+func (s *clientSession) stop(logPrefix string) {
 	// Stop cMix network follower
-	err := c.user.StopNetworkFollower()
+	err := s.user.StopNetworkFollower()
 	if err != nil {
-		jww.ERROR.Printf("[%s] Failed to stop cMix network follower: %+v", c.logPrefix, err)
+		jww.ERROR.Printf("[%s] Failed to stop cMix network follower: %+v", logPrefix, err)
 	} else {
-		jww.INFO.Printf("[%s] Stopped cMix network follower", c.logPrefix)
+		jww.INFO.Printf("[%s] Stopped cMix network follower", logPrefix)
 	}
 
 	// Close Stream
-	c.stream.Close()
-	jww.INFO.Printf("[%s] Stopped cMix Client", c.logPrefix)
-}
+	s.stream.Close()
 
-type Request struct {
-	Method  restlike.Method
-	Uri     string
-	Data    []byte
-	Headers []byte
-}
-
-// ---------------------------- //
-// Send a single-use REST request to a given contact
-func (c *Client) Request(name string, contact contact.Contact, req Request) (*restlike.Message, error) {
-	// Build request
-	request := restSingle.Request{
-		Net:    c.user.GetCmix(),
-		Rng:    c.stream,
-		E2eGrp: c.grp,
-	}
-
-	// Send request and wait for response
-	jww.INFO.Printf("[%s] Sending request over cMix to %s", c.logPrefix, name)
-	response, err := request.Request(contact,
-		req.Method, restlike.URI(req.Uri), req.Data, &restlike.Headers{Headers: req.Headers},
-		single.GetDefaultRequestParams(),
-	)
-	if err != nil {
-		jww.ERROR.Printf("[%s] Failed to send request over cMix: %+v", c.logPrefix, err)
-		return nil, err
+	// Remove ephemeral state
+	if s.dir != "" {
+		err = os.RemoveAll(s.dir)
+		if err != nil {
+			jww.ERROR.Printf("[%s] Failed to remove temporary state at %v: %+v", logPrefix, s.dir, err)
+		}
 	}
-	return response, nil
 }
@@ -1,7 +1,9 @@
 package cmix
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/crypto/contact"
@@ -17,6 +19,13 @@ type Config struct {
 	NdfUrl        string
 	StatePath     string
 	StatePassword string
+
+	// Client identity lifetime
+	Identity IdentityConfig
+
+	// Time allowed for requests in progress to finish on stop
+	// Requests are waited for without limit if zero
+	DrainTimeout time.Duration
 }
 
 func LoadContactFile(file string) contact.Contact {
@@ -28,6 +37,20 @@ func LoadContactFile(file string) contact.Contact {
 	return UnmarshalContact(contactData)
 }
 
+// Read a server contact from file
+// Unlike LoadContactFile, returns an error instead of panicking
+func ReadContactFile(file string) (contact.Contact, error) {
+	contactData, err := os.ReadFile(file)
+	if err != nil {
+		return contact.Contact{}, fmt.Errorf("failed to read server contact file: %w", err)
+	}
+	serverContact, err := contact.Unmarshal(contactData)
+	if err != nil {
+		return contact.Contact{}, fmt.Errorf("failed to get server contact data: %w", err)
+	}
+	return serverContact, nil
+}
+
 func UnmarshalContact(data []byte) contact.Contact {
 	// Unmarshal contact data
 	serverContact, err := contact.Unmarshal(data)
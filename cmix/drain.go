@@ -0,0 +1,81 @@
+package cmix
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Error returned for requests received while draining
+var ErrShuttingDown = errors.New("shutting down")
+
+// ---------------------------- //
+// RequestTracker counts requests in progress,
+// so that they can be drained on shutdown
+// Once draining starts, new requests are rejected
+type RequestTracker struct {
+	mux      sync.Mutex
+	draining bool
+	count    int
+	idle     chan struct{}
+}
+
+// ---------------------------- //
+// Track a new request
+// Returns false if draining, in which case
+// the request must be rejected
+// Otherwise End must be called once the request is done
+func (t *RequestTracker) Begin() bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.draining {
+		return false
+	}
+	t.count++
+	return true
+}
+
+// ---------------------------- //
+// Mark a tracked request as done
+func (t *RequestTracker) End() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.count--
+	if t.count == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+// ---------------------------- //
+// Stop accepting new requests and wait until
+// the requests in progress are done, or the timeout expires
+// The timeout is ignored if zero
+// Returns the number of requests still in progress,
+// which are dropped by the caller
+func (t *RequestTracker) Drain(timeout time.Duration) int {
+	t.mux.Lock()
+	t.draining = true
+	if t.count == 0 {
+		t.mux.Unlock()
+		return 0
+	}
+	idle := make(chan struct{})
+	t.idle = idle
+	t.mux.Unlock()
+
+	if timeout <= 0 {
+		<-idle
+		return 0
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+		return 0
+	case <-timer.C:
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.count
+}
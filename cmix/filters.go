@@ -0,0 +1,222 @@
+package cmix
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// ---------------------------- //
+// JSON-RPC filters
+//
+// Filters created with eth_newFilter, eth_newBlockFilter or
+// eth_newPendingTransactionFilter are state of the node that created them,
+// so requests using a filter must reach the same node
+// FilterRoutes gives out random filter ids and remembers where each
+// filter was created, and the filter id given there, so that requests
+// can be routed to it. Since ids are random, ids given by different
+// targets can't collide
+// Filters not used for filterTimeout are forgotten, like nodes do
+
+// Time after which unused filters are forgotten
+const filterTimeout = 5 * time.Minute
+
+// Maximum number of filters of a route table
+const maxFilters = 10000
+
+// Methods that create a filter, returning its id
+var filterCreateMethods = map[string]struct{}{
+	"eth_newFilter":                   {},
+	"eth_newBlockFilter":              {},
+	"eth_newPendingTransactionFilter": {},
+}
+
+// Methods that take a filter id as first parameter
+var filterUseMethods = map[string]struct{}{
+	"eth_getFilterChanges": {},
+	"eth_getFilterLogs":    {},
+	"eth_uninstallFilter":  {},
+}
+
+// FilterRequest is a JSON-RPC request with the fields needed for routing
+type FilterRequest struct {
+	Jsonrpc string            `json:"jsonrpc"`
+	Id      json.RawMessage   `json:"id,omitempty"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+// JSON-RPC response with the fields needed for routing
+type filterResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// ---------------------------- //
+// Parse a single JSON-RPC request that creates or uses a filter
+// Returns nil for any other request, including batch requests
+func ParseFilterRequest(data []byte) *FilterRequest {
+	var req FilterRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil
+	}
+	if _, ok := filterCreateMethods[req.Method]; ok {
+		return &req
+	}
+	if _, ok := filterUseMethods[req.Method]; ok {
+		return &req
+	}
+	return nil
+}
+
+// Whether the request creates a filter
+func (r *FilterRequest) Creates() bool {
+	_, ok := filterCreateMethods[r.Method]
+	return ok
+}
+
+// Get the filter id of a request using a filter
+// Returns false if it is missing
+func (r *FilterRequest) FilterId() (string, bool) {
+	var id string
+	if len(r.Params) == 0 || json.Unmarshal(r.Params[0], &id) != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// Whether the request removes its filter
+func (r *FilterRequest) Uninstalls() bool {
+	return r.Method == "eth_uninstallFilter"
+}
+
+// Build the request for the target of a filter,
+// replacing the filter id with the target filter id
+func (r *FilterRequest) ForTarget(filter *Filter) ([]byte, error) {
+	params := append([]json.RawMessage{filter.Id}, r.Params[1:]...)
+	return json.Marshal(FilterRequest{Jsonrpc: r.Jsonrpc, Id: r.Id, Method: r.Method, Params: params})
+}
+
+// Build the response to a request using a filter that doesn't exist
+// Uninstalling returns false, other methods a filter not found error
+func (r *FilterRequest) NotFound() []byte {
+	if r.Uninstalls() {
+		data, _ := json.Marshal(filterResponse{Jsonrpc: "2.0", Id: r.Id, Result: json.RawMessage("false")})
+		return data
+	}
+	return FilterError(r.Id, -32000, "filter not found")
+}
+
+// Build a JSON-RPC error response
+func FilterError(id json.RawMessage, code int, message string) []byte {
+	rpcErr, _ := json.Marshal(map[string]interface{}{"code": code, "message": message})
+	data, _ := json.Marshal(filterResponse{Jsonrpc: "2.0", Id: id, Error: rpcErr})
+	return data
+}
+
+// ---------------------------- //
+// Filter is the route of a filter
+type Filter struct {
+	// Where the filter was created, such as an endpoint URL
+	Target string
+	// Network of the filter, if the table is shared by networks
+	Network string
+	// Filter id given by the target
+	Id json.RawMessage
+
+	lastUsed time.Time
+}
+
+// FilterRoutes maps the filter ids given out to their routes
+// The zero value is an empty table
+type FilterRoutes struct {
+	mux     sync.Mutex
+	filters map[string]*Filter
+}
+
+// ---------------------------- //
+// Add a filter created on a target
+// Returns the filter id to give out
+func (f *FilterRoutes) Add(target, network string, targetId json.RawMessage) string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	id := "0x" + hex.EncodeToString(buf)
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.filters == nil {
+		f.filters = make(map[string]*Filter)
+	}
+	f.filters[id] = &Filter{
+		Target:   target,
+		Network:  network,
+		Id:       targetId,
+		lastUsed: time.Now(),
+	}
+	return id
+}
+
+// Add the filter created by a response of a target,
+// replacing its id in the response with the id given out
+// Responses without a filter id, such as errors, are returned
+// as they are, with an empty id
+func (f *FilterRoutes) AddResponse(target, network string, resp []byte) ([]byte, string, error) {
+	var response filterResponse
+	if json.Unmarshal(resp, &response) != nil || len(response.Result) == 0 || string(response.Result) == "null" {
+		return resp, "", nil
+	}
+	id := f.Add(target, network, response.Result)
+	response.Result, _ = json.Marshal(id)
+	data, err := json.Marshal(response)
+	if err != nil {
+		f.Remove(id)
+		return nil, "", err
+	}
+	return data, id, nil
+}
+
+// Get the route of a filter, updating its last use
+// If remove is set, the filter is removed
+// Returns nil if the filter doesn't exist or expired
+func (f *FilterRoutes) Get(id string, remove bool) *Filter {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	filter, ok := f.filters[id]
+	if !ok {
+		return nil
+	}
+	expired := time.Since(filter.lastUsed) > filterTimeout
+	if remove || expired {
+		delete(f.filters, id)
+	}
+	if expired {
+		return nil
+	}
+	filter.lastUsed = time.Now()
+	route := *filter
+	return &route
+}
+
+// Remove a filter
+func (f *FilterRoutes) Remove(id string) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	delete(f.filters, id)
+}
+
+// Remove expired filters, and check if
+// the maximum number of filters is reached
+func (f *FilterRoutes) Full() bool {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for id, filter := range f.filters {
+		if time.Since(filter.lastUsed) > filterTimeout {
+			delete(f.filters, id)
+		}
+	}
+	return len(f.filters) >= maxFilters
+}
@@ -0,0 +1,49 @@
+package cmix
+
+import "testing"
+
+func TestParseFilterRequest(t *testing.T) {
+	tests := map[string]bool{
+		`{"jsonrpc":"2.0","id":1,"method":"eth_newFilter","params":[{"fromBlock":"latest"}]}`: true,
+		`{"jsonrpc":"2.0","id":1,"method":"eth_newBlockFilter","params":[]}`:                  true,
+		`{"jsonrpc":"2.0","id":1,"method":"eth_getFilterChanges","params":["0x1"]}`:           true,
+		`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`:                     false,
+		`[{"jsonrpc":"2.0","id":1,"method":"eth_getFilterChanges","params":["0x1"]}]`:         false,
+		`{"jsonrpc":"2.0","id":1,"method":"eth_getFilterChanges","params":{"id":"0x1"}}`:      false,
+	}
+	for data, want := range tests {
+		if got := ParseFilterRequest([]byte(data)) != nil; got != want {
+			t.Errorf("ParseFilterRequest(%s) = %v, want %v", data, got, want)
+		}
+	}
+}
+
+// Filter ids given out are replaced with the target filter id
+func TestFilterRoutes(t *testing.T) {
+	var routes FilterRoutes
+	resp, id, err := routes.AddResponse("endpoint-1", "", []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	if err != nil || id == "" {
+		t.Fatalf("AddResponse() = %s, %q, %v", resp, id, err)
+	}
+	if want := `{"jsonrpc":"2.0","id":1,"result":"` + id + `"}`; string(resp) != want {
+		t.Errorf("AddResponse() = %s, want %s", resp, want)
+	}
+	req := ParseFilterRequest([]byte(`{"jsonrpc":"2.0","id":2,"method":"eth_uninstallFilter","params":["` + id + `"]}`))
+	filter := routes.Get(id, req.Uninstalls())
+	if filter == nil || filter.Target != "endpoint-1" {
+		t.Fatalf("Get() = %+v, want filter on endpoint-1", filter)
+	}
+	data, err := req.ForTarget(filter)
+	if want := `{"jsonrpc":"2.0","id":2,"method":"eth_uninstallFilter","params":["0x1"]}`; err != nil || string(data) != want {
+		t.Errorf("ForTarget() = %s, %v, want %s", data, err, want)
+	}
+	if routes.Get(id, false) != nil {
+		t.Error("uninstalled filter wasn't removed")
+	}
+
+	// Errors aren't filters
+	errResp := []byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"failed"}}`)
+	if resp, id, err := routes.AddResponse("endpoint-1", "", errResp); err != nil || id != "" || string(resp) != string(errResp) {
+		t.Errorf("AddResponse() of error = %s, %q, %v, want unchanged", resp, id, err)
+	}
+}
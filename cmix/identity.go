@@ -0,0 +1,92 @@
+package cmix
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ---------------------------- //
+// IdentityConfig controls the lifetime of the
+// cMix identity used by a Client to send requests
+//
+// A persistent identity is loaded from the state directory and
+// reused across restarts, so it has the lowest startup cost
+// Session and rotating identities use a fresh cMix state
+// in a temporary directory, which must register with the
+// network nodes before sending requests, but makes requests
+// sent with different identities unlinkable
+type IdentityConfig struct {
+	Policy IdentityPolicy
+
+	// Rotate the identity after this interval, for IdentityRotate policy
+	// Time based rotation is disabled if this is zero
+	RotateInterval time.Duration
+
+	// Rotate the identity after this number of requests, for IdentityRotate policy
+	// Request based rotation is disabled if this is zero
+	RotateRequests uint64
+}
+
+// IdentityPolicy selects the lifetime of the client identity
+type IdentityPolicy string
+
+const (
+	// Identity is stored in the state directory and reused across restarts
+	IdentityPersistent IdentityPolicy = "persistent"
+	// A new identity is created on each start and discarded on stop
+	IdentitySession IdentityPolicy = "session"
+	// A new identity is created on each start and replaced
+	// periodically, after an interval or number of requests
+	IdentityRotate IdentityPolicy = "rotate"
+)
+
+// Storage key of the persistent reception identity
+const identityStorageKey = "identityStorageKey"
+
+// ---------------------------- //
+// Parse an identity policy
+func ParseIdentityPolicy(policy string) (IdentityPolicy, error) {
+	switch IdentityPolicy(policy) {
+	case IdentityPersistent, IdentitySession, IdentityRotate:
+		return IdentityPolicy(policy), nil
+	case "":
+		return IdentityPersistent, nil
+	}
+	return IdentityPersistent, fmt.Errorf("invalid identity policy %s", policy)
+}
+
+// ---------------------------- //
+// Return true if the identity is discarded on stop
+func (i IdentityConfig) Ephemeral() bool {
+	return i.Policy == IdentitySession || i.Policy == IdentityRotate
+}
+
+// Return true if the identity is rotated while running
+func (i IdentityConfig) Rotates() bool {
+	return i.Policy == IdentityRotate && (i.RotateInterval > 0 || i.RotateRequests > 0)
+}
+
+// ---------------------------- //
+// Create a temporary directory for an ephemeral cMix state
+// Returns the directory, which must be removed when the state
+// is no longer used, and the path of the state inside it
+func ephemeralStatePath() (string, string, error) {
+	dir, err := os.MkdirTemp("", "cmix-identity-")
+	if err != nil {
+		return "", "", err
+	}
+	return dir, filepath.Join(dir, "state"), nil
+}
+
+// Generate a random password for an ephemeral cMix state
+func ephemeralPassword() (string, error) {
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(password), nil
+}
@@ -0,0 +1,108 @@
+package cmix
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// ---------------------------- //
+// JSON-RPC helpers shared by relay servers and clients
+
+// Minimal representation of a JSON-RPC request
+// Only the method is parsed
+type jsonRpcMethod struct {
+	Method string `json:"method"`
+}
+
+// Minimal representation of a JSON-RPC response
+// Only the error is parsed
+type jsonRpcError struct {
+	Error json.RawMessage `json:"error"`
+}
+
+// JSON-RPC methods that submit transactions to a blockchain network
+// These methods change state, so they can have a different
+// policy than read methods when sent to multiple relay servers
+// or endpoints
+var writeMethods = map[string]struct{}{
+	// EVM
+	"eth_sendRawTransaction": {},
+	"eth_sendTransaction":    {},
+	// Bitcoin
+	"sendrawtransaction": {},
+	// Solana
+	"sendTransaction": {},
+	// Substrate
+	"author_submitExtrinsic":         {},
+	"author_submitAndWatchExtrinsic": {},
+	// Tendermint
+	"broadcast_tx_async":  {},
+	"broadcast_tx_sync":   {},
+	"broadcast_tx_commit": {},
+}
+
+// ---------------------------- //
+// Parse the methods from JSON-RPC request data
+// Supports single and batch requests
+// Returns nil if the data is not a valid JSON-RPC request
+func ParseMethods(data []byte) []string {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+	// Batch request
+	if data[0] == '[' {
+		var batch []jsonRpcMethod
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return nil
+		}
+		methods := make([]string, len(batch))
+		for i, req := range batch {
+			methods[i] = req.Method
+		}
+		return methods
+	}
+	// Single request
+	var req jsonRpcMethod
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil
+	}
+	return []string{req.Method}
+}
+
+// Check if the JSON-RPC request data (single or batch)
+// contains a method that submits a transaction
+func IsWriteRequest(data []byte) bool {
+	for _, method := range ParseMethods(data) {
+		if _, ok := writeMethods[method]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if a JSON-RPC response (single or batch) contains an error
+func HasJsonRpcError(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return false
+	}
+	var batch []jsonRpcError
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return false
+		}
+	} else {
+		var single jsonRpcError
+		if err := json.Unmarshal(data, &single); err != nil {
+			return false
+		}
+		batch = []jsonRpcError{single}
+	}
+	for _, resp := range batch {
+		if len(resp.Error) > 0 && string(resp.Error) != "null" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package cmix
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// ---------------------------- //
+// LogMode controls logging of request and response data
+// Data can contain addresses and signed transactions,
+// so bodies are only logged at trace level in LogFull mode
+type LogMode string
+
+const (
+	// Log metadata of requests, and bodies at trace level
+	LogFull LogMode = "full"
+	// Log metadata of requests only, such as their size
+	LogMetadata LogMode = "metadata"
+	// Don't log requests
+	LogNone LogMode = "none"
+)
+
+// Replacement of secrets in redacted URLs
+const redacted = "REDACTED"
+
+// Minimum length of a path segment considered an API key
+const minSecretLength = 20
+
+// ---------------------------- //
+// Parse a log mode
+func ParseLogMode(mode string) (LogMode, error) {
+	switch LogMode(mode) {
+	case LogFull, LogMetadata, LogNone:
+		return LogMode(mode), nil
+	case "":
+		return LogMetadata, nil
+	}
+	return LogMetadata, fmt.Errorf("invalid log mode %s", mode)
+}
+
+// ---------------------------- //
+// Log a request or response according to the mode
+// The message and data size are logged at info level,
+// and the data at trace level in LogFull mode
+func (m LogMode) Log(prefix, msg string, data []byte) {
+	if m == LogNone {
+		return
+	}
+	jww.INFO.Printf("[%s] %s (%d bytes)", prefix, msg, len(data))
+	if m == LogFull {
+		jww.TRACE.Printf("[%s] %s: %s", prefix, msg, data)
+	}
+}
+
+// ---------------------------- //
+// Redact secrets from a URL, so that it can be logged
+// Removes user info, query values and path segments
+// that look like API keys
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query[key] = []string{redacted}
+		}
+		u.RawQuery = query.Encode()
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		if isSecret(segment) {
+			segments[i] = redacted
+		}
+	}
+	u.Path = strings.Join(segments, "/")
+	u.RawPath = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// Check if a path segment looks like an API key:
+// a long token mixing letters and digits
+func isSecret(segment string) bool {
+	if len(segment) < minSecretLength {
+		return false
+	}
+	letters, digits := false, false
+	for _, c := range segment {
+		switch {
+		case c >= '0' && c <= '9':
+			digits = true
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			letters = true
+		case c == '-' || c == '_':
+		default:
+			return false
+		}
+	}
+	return letters && digits
+}
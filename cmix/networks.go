@@ -0,0 +1,103 @@
+package cmix
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ---------------------------- //
+// Networks listing of relay servers
+//
+// Relay servers list their supported networks on the /networks endpoint
+// Version 1 of the listing is a JSON array of network URIs:
+//
+//	["/ethereum/mainnet", "/custom"]
+//
+// Version 2 is a JSON array of network objects,
+// with the chain ID and aliases of each network:
+//
+//	[{"uri": "/ethereum/mainnet", "chainId": 1, "aliases": ["eth"]}, {"uri": "/custom"}]
+//
+// Clients request version 2 with a NetworksRequest in the request content
+// Relay servers that don't support it ignore the content and return version 1
+
+// Latest version of the networks listing
+const NetworksVersion = 2
+
+// NetworksRequest is the content of a request for the networks listing
+type NetworksRequest struct {
+	Version int `json:"version"`
+}
+
+// NetworkInfo describes a network supported by a relay server
+type NetworkInfo struct {
+	// URI of the network, such as /ethereum/mainnet
+	Uri string `json:"uri"`
+
+	// Chain ID of the network, zero if unknown
+	ChainId uint64 `json:"chainId,omitempty"`
+
+	// Alternative names of the network, such as eth
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Protocol of the network endpoints,
+	// empty for the default JSON-RPC
+	Adapter Adapter `json:"adapter,omitempty"`
+}
+
+// ---------------------------- //
+// Adapter is the protocol used to query the endpoints of a network
+type Adapter string
+
+const (
+	// JSON-RPC requests sent with HTTP POST
+	AdapterJsonRpc Adapter = "jsonrpc"
+	// REST requests, with the HTTP method and path
+	// sent in the request headers as a RestRequest
+	AdapterRest Adapter = "rest"
+	// GraphQL requests sent with HTTP POST
+	AdapterGraphQL Adapter = "graphql"
+)
+
+// Parse an adapter, the default being JSON-RPC
+func ParseAdapter(adapter string) (Adapter, error) {
+	switch a := Adapter(adapter); a {
+	case "":
+		return AdapterJsonRpc, nil
+	case AdapterJsonRpc, AdapterRest, AdapterGraphQL:
+		return a, nil
+	}
+	return "", fmt.Errorf("invalid adapter %s", adapter)
+}
+
+// ---------------------------- //
+// RestRequest is the HTTP method and path of a request
+// to a network with the REST adapter
+// It is sent as JSON in the request headers,
+// and the request content is the HTTP body
+type RestRequest struct {
+	Method string `json:"method"`
+
+	// Path relative to the network endpoint, with query
+	Path string `json:"path"`
+}
+
+// ---------------------------- //
+// Parse a networks listing of any version
+func ParseNetworks(data []byte) ([]NetworkInfo, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid networks listing: %v", err)
+	}
+	networks := make([]NetworkInfo, len(items))
+	for i, item := range items {
+		// Version 1 items are URIs
+		if err := json.Unmarshal(item, &networks[i].Uri); err == nil {
+			continue
+		}
+		if err := json.Unmarshal(item, &networks[i]); err != nil {
+			return nil, fmt.Errorf("invalid network in listing: %v", err)
+		}
+	}
+	return networks, nil
+}
@@ -0,0 +1,120 @@
+package cmix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ---------------------------- //
+// Padding of request and response data
+// so that their size over cMix doesn't leak
+// which request is being made
+//
+// Padded data is wrapped in an envelope:
+//
+//	magic (4 bytes) | data length (4 bytes) | data | zeros
+//
+// The magic prefix can't start valid JSON or text data,
+// so padded data is detected and stripped transparently
+type Padding struct {
+	Mode PaddingMode
+
+	// Size of each block, for PaddingBlock mode
+	BlockSize int
+}
+
+// PaddingMode selects how data is padded
+type PaddingMode string
+
+const (
+	// Data is not padded
+	PaddingNone PaddingMode = "none"
+	// Data is padded to the next power of two, starting at MinBucketSize
+	PaddingBuckets PaddingMode = "buckets"
+	// Data is padded to the next multiple of the block size
+	PaddingBlock PaddingMode = "block"
+)
+
+// Smallest padded size in PaddingBuckets mode
+const MinBucketSize = 256
+
+// Default block size in PaddingBlock mode
+const DefaultBlockSize = 1024
+
+var paddingMagic = []byte{0x00, 'P', 'A', 'D'}
+
+const paddingHeaderSize = 8
+
+var errPaddingInvalid = errors.New("invalid padding envelope")
+
+// ---------------------------- //
+// Parse a padding mode
+func ParsePaddingMode(mode string) (PaddingMode, error) {
+	switch PaddingMode(mode) {
+	case PaddingNone, PaddingBuckets, PaddingBlock:
+		return PaddingMode(mode), nil
+	case "":
+		return PaddingNone, nil
+	}
+	return PaddingNone, fmt.Errorf("invalid padding mode %s", mode)
+}
+
+// ---------------------------- //
+// Return true if padding is enabled
+func (p Padding) Enabled() bool {
+	return p.Mode == PaddingBuckets || p.Mode == PaddingBlock
+}
+
+// ---------------------------- //
+// Pad data according to the padding mode
+// Returns the data unchanged if padding is disabled
+func (p Padding) Pad(data []byte) []byte {
+	if !p.Enabled() {
+		return data
+	}
+	size := p.paddedSize(paddingHeaderSize + len(data))
+	padded := make([]byte, size)
+	copy(padded, paddingMagic)
+	binary.BigEndian.PutUint32(padded[len(paddingMagic):], uint32(len(data)))
+	copy(padded[paddingHeaderSize:], data)
+	return padded
+}
+
+// Return the padded size for the given size
+func (p Padding) paddedSize(size int) int {
+	if p.Mode == PaddingBlock {
+		block := p.BlockSize
+		if block <= 0 {
+			block = DefaultBlockSize
+		}
+		return ((size + block - 1) / block) * block
+	}
+	padded := MinBucketSize
+	for padded < size {
+		padded *= 2
+	}
+	return padded
+}
+
+// ---------------------------- //
+// Return true if data is wrapped in a padding envelope
+func IsPadded(data []byte) bool {
+	return len(data) >= paddingHeaderSize && bytes.Equal(data[:len(paddingMagic)], paddingMagic)
+}
+
+// ---------------------------- //
+// Strip the padding envelope from data
+// Data that isn't padded is returned unchanged
+// Returns the data and whether it was padded
+func Unpad(data []byte) ([]byte, bool, error) {
+	if !IsPadded(data) {
+		return data, false, nil
+	}
+	size := binary.BigEndian.Uint32(data[len(paddingMagic):])
+	if uint64(size) > uint64(len(data)-paddingHeaderSize) {
+		return nil, true, errPaddingInvalid
+	}
+	return data[paddingHeaderSize : paddingHeaderSize+int(size)], true, nil
+}
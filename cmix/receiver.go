@@ -0,0 +1,70 @@
+package cmix
+
+import (
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/client/v4/cmix"
+	"gitlab.com/elixxir/client/v4/cmix/identity/receptionID"
+	"gitlab.com/elixxir/client/v4/cmix/rounds"
+	"gitlab.com/elixxir/client/v4/restlike"
+	"gitlab.com/elixxir/client/v4/single"
+	"google.golang.org/protobuf/proto"
+)
+
+// Timeout for sending a response over cMix
+const respondTimeout = 30 * time.Second
+
+// ---------------------------- //
+// receiver handles single use restlike requests for the Server
+// It works like the xxDK restlike server, but tracks each request
+// until its response is sent, so that requests can be drained on stop
+type receiver struct {
+	endpoints *restlike.Endpoints
+	requests  *RequestTracker
+	logPrefix string
+}
+
+// Callback for single use requests
+// Requests received while draining are answered with an error,
+// so that clients can retry them on another server
+func (r *receiver) Callback(req *single.Request,
+	receptionId receptionID.EphemeralIdentity, rounds []rounds.Round) {
+	// Unmarshal the request payload
+	request := &restlike.Message{}
+	err := proto.Unmarshal(req.GetPayload(), request)
+	if err != nil {
+		jww.ERROR.Printf("[%s] Unable to unmarshal restlike request: %+v", r.logPrefix, err)
+		return
+	}
+
+	var response *restlike.Message
+	if !r.requests.Begin() {
+		response = &restlike.Message{Error: "Server is " + ErrShuttingDown.Error()}
+		r.respond(req, response)
+		return
+	}
+	defer r.requests.End()
+
+	// Send the request to the endpoint callback, if it exists
+	cb, err := r.endpoints.Get(restlike.URI(request.GetUri()), restlike.Method(request.GetMethod()))
+	if err != nil {
+		response = &restlike.Message{Error: err.Error()}
+	} else {
+		response = cb(request)
+	}
+	r.respond(req, response)
+}
+
+// Send the response to a single use request
+func (r *receiver) respond(req *single.Request, response *restlike.Message) {
+	payload, err := proto.Marshal(response)
+	if err != nil {
+		jww.ERROR.Printf("[%s] Unable to marshal restlike response: %+v", r.logPrefix, err)
+		return
+	}
+	_, err = req.Respond(payload, cmix.GetDefaultCMIXParams(), respondTimeout)
+	if err != nil {
+		jww.ERROR.Printf("[%s] Unable to send restlike response: %+v", r.logPrefix, err)
+	}
+}
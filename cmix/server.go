@@ -5,8 +5,9 @@ import (
 	"time"
 
 	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/client/v4/catalog"
 	"gitlab.com/elixxir/client/v4/restlike"
-	"gitlab.com/elixxir/client/v4/restlike/single"
+	"gitlab.com/elixxir/client/v4/single"
 	"gitlab.com/elixxir/client/v4/xxdk"
 	"gitlab.com/xx_network/primitives/utils"
 )
@@ -14,9 +15,12 @@ import (
 // ---------------------------- //
 // Server holds the REST Server and xxDK user
 type Server struct {
-	restServer *single.Server
-	user       *xxdk.E2e
-	logPrefix  string
+	listener     single.Listener
+	endpoints    *restlike.Endpoints
+	requests     RequestTracker
+	drainTimeout time.Duration
+	user         *xxdk.E2e
+	logPrefix    string
 }
 
 // ---------------------------- //
@@ -27,7 +31,22 @@ type Server struct {
 // and writes the contact information to the provided filepath
 func InitializeServer(c Config, outputFile string) {
 	// Create Server
-	newServer(c, outputFile)
+	_, identity := newServer(c, true)
+
+	// Save the server contact file at the provided filepath
+	writeContact(c, identity, outputFile)
+}
+
+// Export the contact information of an existing cMix Server
+// This function loads the state from the configured path,
+// without modifying it, and writes the contact information
+// to the provided filepath
+func ExportServer(c Config, outputFile string) {
+	// Load Server
+	_, identity := newServer(c, false)
+
+	// Save the server contact file at the provided filepath
+	writeContact(c, identity, outputFile)
 }
 
 // Load a cMix RestLike Server
@@ -35,7 +54,7 @@ func InitializeServer(c Config, outputFile string) {
 // It panics if the state directory doesn't exist
 func LoadServer(c Config) *Server {
 	// Create Server
-	net, identity := newServer(c, "")
+	net, identity := newServer(c, false)
 
 	// Create an E2E client
 	params := xxdk.GetDefaultE2EParams()
@@ -57,20 +76,22 @@ func LoadServer(c Config) *Server {
 	}
 
 	// Initialize the server
-	restServer := single.NewServer(identity.ID, dhKeyPrivateKey, grp, user.GetCmix())
-	jww.INFO.Printf("[%s] Initialized single use REST Server", c.LogPrefix)
-
-	return &Server{
-		restServer,
-		user,
-		c.LogPrefix,
+	s := &Server{
+		endpoints:    restlike.NewEndpoints(),
+		drainTimeout: c.DrainTimeout,
+		user:         user,
+		logPrefix:    c.LogPrefix,
 	}
+	s.listener = single.Listen(catalog.RestLike, identity.ID, dhKeyPrivateKey,
+		user.GetCmix(), grp, &receiver{s.endpoints, &s.requests, c.LogPrefix})
+	jww.INFO.Printf("[%s] Initialized single use REST Server", c.LogPrefix)
+	return s
 }
 
 // ---------------------------- //
 // Get REST Server endpoints
 func (s *Server) GetEndpoints() *restlike.Endpoints {
-	return s.restServer.GetEndpoints()
+	return s.endpoints
 }
 
 // ---------------------------- //
@@ -110,7 +131,17 @@ func (s *Server) Start() {
 
 // ---------------------------- //
 // Stop the REST Server
+// New requests are rejected, and requests in progress are
+// given the drain timeout to finish before stopping
 func (s *Server) Stop() {
+	// Drain requests
+	jww.INFO.Printf("[%s] Draining requests in progress", s.logPrefix)
+	if dropped := s.requests.Drain(s.drainTimeout); dropped > 0 {
+		jww.WARN.Printf("[%s] Dropped %d requests in progress after %v", s.logPrefix, dropped, s.drainTimeout)
+	} else {
+		jww.INFO.Printf("[%s] Drained all requests in progress", s.logPrefix)
+	}
+
 	// Stop cMix network follower
 	err := s.user.StopNetworkFollower()
 	if err != nil {
@@ -120,7 +151,7 @@ func (s *Server) Stop() {
 	}
 
 	// Close REST server
-	s.restServer.Close()
+	s.listener.Stop()
 	jww.INFO.Printf("[%s] Stopped REST Server", s.logPrefix)
 }
 
@@ -128,12 +159,10 @@ func (s *Server) Stop() {
 // Internal functions
 // ---------------------------- //
 
-func newServer(c Config, outputFile string) (*xxdk.Cmix, xxdk.ReceptionIdentity) {
+func newServer(c Config, initialize bool) (*xxdk.Cmix, xxdk.ReceptionIdentity) {
 	// Initialize state if requested
 	// Overwrites existing state if found at provided path
 	_, err := os.Stat(c.StatePath)
-	// If an ouput file is provided, initialize the state
-	initialize := outputFile != ""
 	if initialize {
 		if err == nil {
 			jww.INFO.Printf("[%s] Removing existing state at %v", c.LogPrefix, c.StatePath)
@@ -187,13 +216,13 @@ func newServer(c Config, outputFile string) (*xxdk.Cmix, xxdk.ReceptionIdentity)
 		}
 	}
 
-	// Save the server contact file at the provided filepath
-	if initialize {
-		err = utils.WriteFileDef(outputFile, identity.GetContact().Marshal())
-		if err != nil {
-			jww.FATAL.Panicf("[%s] Failed writing contact file to %v: %+v", c.LogPrefix, outputFile, err)
-		}
-	}
-
 	return net, identity
 }
+
+func writeContact(c Config, identity xxdk.ReceptionIdentity, outputFile string) {
+	err := utils.WriteFileDef(outputFile, identity.GetContact().Marshal())
+	if err != nil {
+		jww.FATAL.Panicf("[%s] Failed writing contact file to %v: %+v", c.LogPrefix, outputFile, err)
+	}
+	jww.INFO.Printf("[%s] Wrote contact file to %v", c.LogPrefix, outputFile)
+}
@@ -0,0 +1,93 @@
+package command
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/xx-labs/blockchain-cmix-relay/common/config"
+)
+
+// ---------------------------- //
+// Shared scaffolding of the cMix relay and client commands
+//
+// The root command created here has the config file, cMix state,
+// NDF download, logging and shutdown flags, reads the config file,
+// environment variables and state password, and initializes logging
+// before running
+// Servers get the init and export subcommands, and clients get
+// the reset subcommand, all using the same shared settings
+
+// Options of a root command
+type Options struct {
+	// Command description
+	Use   string
+	Short string
+	Long  string
+
+	// Prefix of environment variables setting flags
+	EnvPrefix string
+
+	// Default logging prefix and log file
+	LogPrefix string
+	LogFile   string
+
+	// Default path of the contact file written by the init
+	// and export subcommands
+	// If empty, the command is a client and gets the reset
+	// subcommand instead
+	ContactFile string
+
+	// Run the command, after logging is initialized
+	Run func(cmd *cobra.Command, args []string)
+}
+
+// ---------------------------- //
+// Create a root command with the shared flags and subcommands
+// The shared flags are bound to the given settings
+func New(o Options, s *Settings) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   o.Use,
+		Short: o.Short,
+		Long:  o.Long,
+		Args:  cobra.NoArgs,
+		// Read config file, environment variables and state password,
+		// then initialize logging
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := config.PreRun(o.EnvPrefix)(cmd, args); err != nil {
+				return err
+			}
+			s.InitLog()
+			return nil
+		},
+		Run: o.Run,
+	}
+
+	// Config file
+	config.AddFlags(cmd)
+	cmd.AddCommand(config.NewPrintCommand(o.EnvPrefix))
+
+	// Shared flags, inherited by subcommands
+	s.addFlags(cmd.PersistentFlags(), o)
+	cmd.MarkPersistentFlagRequired("statePassword")
+
+	// State subcommands
+	if o.ContactFile != "" {
+		cmd.AddCommand(newInitCommand(s, o.ContactFile))
+		cmd.AddCommand(newExportCommand(s, o.ContactFile))
+	} else {
+		cmd.AddCommand(newResetCommand(s))
+	}
+	return cmd
+}
+
+// ---------------------------- //
+// Execute the root command
+// This is called by main.main() and exits on error
+func Execute(cmd *cobra.Command, s *Settings) {
+	if err := cmd.Execute(); err != nil {
+		jww.ERROR.Printf("[%s] Exiting with error: %s", s.LogPrefix, err.Error())
+		os.Exit(1)
+	}
+	jww.INFO.Printf("[%s] Exiting without error...", s.LogPrefix)
+}
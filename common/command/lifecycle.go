@@ -0,0 +1,95 @@
+package command
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// ---------------------------- //
+// Component of a command that is started and stopped with it
+type Component struct {
+	// Name used in logs
+	Name string
+
+	// Start the component, optional
+	// Must return once started, components that block
+	// while running start themselves in a goroutine
+	Start func()
+
+	// Stop the component, optional
+	Stop func()
+}
+
+// ---------------------------- //
+// Start the components in order, then block until an interrupt
+// or termination signal is received, and stop the components
+// in reverse order
+// Each component is given the shutdown timeout to stop,
+// after which shutdown continues without waiting for it
+// A second signal received during shutdown forces quit
+func (s *Settings) Serve(components ...Component) {
+	// Set up channel on which to send signal notifications.
+	// We must use a buffered channel or risk missing the signal
+	// if we're not ready to receive when the signal is sent.
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(c)
+
+	// Start components
+	for _, component := range components {
+		if component.Start != nil {
+			jww.DEBUG.Printf("[%s] Starting %s", s.LogPrefix, component.Name)
+			component.Start()
+		}
+	}
+
+	// Block to prevent the program ending until a signal is received
+	sig := <-c
+	jww.INFO.Printf("[%s] Received %v, shutting down, send again to force quit", s.LogPrefix, sig)
+
+	// Force quit on second signal
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case sig := <-c:
+			jww.ERROR.Printf("[%s] Received %v during shutdown, forcing quit", s.LogPrefix, sig)
+			os.Exit(1)
+		case <-done:
+		}
+	}()
+
+	// Stop components in reverse order
+	for i := len(components) - 1; i >= 0; i-- {
+		s.stop(components[i])
+	}
+}
+
+// Stop a component, waiting at most the shutdown timeout
+func (s *Settings) stop(component Component) {
+	if component.Stop == nil {
+		return
+	}
+	stopped := make(chan struct{})
+	go func() {
+		component.Stop()
+		close(stopped)
+	}()
+
+	if s.ShutdownTimeout <= 0 {
+		<-stopped
+		return
+	}
+	timer := time.NewTimer(s.ShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+		jww.DEBUG.Printf("[%s] Stopped %s", s.LogPrefix, component.Name)
+	case <-timer.C:
+		jww.WARN.Printf("[%s] Timeout stopping %s after %v, continuing shutdown", s.LogPrefix, component.Name, s.ShutdownTimeout)
+	}
+}
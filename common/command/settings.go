@@ -0,0 +1,95 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+	"github.com/xx-labs/blockchain-cmix-relay/common/config"
+	"github.com/xx-labs/blockchain-cmix-relay/common/logging"
+)
+
+// Default URL used to download the NDF
+const DefaultNdfUrl = "https://elixxir-bins.s3.us-west-1.amazonaws.com/ndf/mainnet.json"
+
+// Default path to the certificate used to verify the NDF
+const DefaultCert = "mainnet.crt"
+
+// Default time allowed for each component to stop on shutdown
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Default time allowed for requests in progress to finish on shutdown
+const DefaultDrainTimeout = 10 * time.Second
+
+// ---------------------------- //
+// Settings shared by all commands
+// They are set from the flags of the root command,
+// which are inherited by all subcommands
+type Settings struct {
+	// cMix state
+	StatePath     string
+	StatePassword string
+
+	// NDF download
+	NdfUrl string
+	Cert   string
+
+	// Logging
+	LogLevel  uint // 0 = info, 1 = debug, >1 = trace
+	LogPath   string
+	LogPrefix string
+	Log       logging.Config
+
+	// Time allowed for each component to stop on shutdown
+	ShutdownTimeout time.Duration
+
+	// Time allowed for requests in progress to finish on shutdown
+	DrainTimeout time.Duration
+}
+
+// ---------------------------- //
+// Register the flags of the shared settings
+func (s *Settings) addFlags(flags *pflag.FlagSet, o Options) {
+	// cMix state
+	flags.StringVarP(&s.StatePath, "statePath", "s", "state", "Path cMix state directory")
+	flags.StringVarP(&s.StatePassword, "statePassword", "p", "", "Password for cMix state")
+	config.AddPasswordFlags(flags)
+
+	// NDF download
+	flags.StringVarP(&s.NdfUrl, "ndf", "d", DefaultNdfUrl, "URL used to download NDF file on initialization")
+	flags.StringVarP(&s.Cert, "cert", "r", DefaultCert, "Path to certificate file used to verify NDF download")
+
+	// Logging
+	flags.UintVarP(&s.LogLevel, "logLevel", "l", 0, "Level of debugging to print (0 = info, 1 = debug, >1 = trace).")
+	flags.StringVarP(&s.LogPath, "logFile", "f", o.LogFile, "Path to log file")
+	flags.StringVarP(&s.LogPrefix, "logPrefix", "", o.LogPrefix, "Logging prefix")
+	logging.AddFlags(flags, &s.Log)
+
+	// Lifecycle
+	flags.DurationVarP(&s.ShutdownTimeout, "shutdownTimeout", "", DefaultShutdownTimeout, "Time allowed for each component to stop on shutdown, a second signal forces quit (0 = no limit)")
+	flags.DurationVarP(&s.DrainTimeout, "drainTimeout", "", DefaultDrainTimeout, "Time allowed for requests in progress to finish on shutdown, new requests are rejected meanwhile (0 = no limit)")
+}
+
+// ---------------------------- //
+// Get the cMix configuration from the shared settings
+func (s *Settings) CmixConfig() cmix.Config {
+	return cmix.Config{
+		LogPrefix:     s.LogPrefix,
+		Cert:          s.Cert,
+		NdfUrl:        s.NdfUrl,
+		StatePath:     s.StatePath,
+		StatePassword: s.StatePassword,
+		DrainTimeout:  s.DrainTimeout,
+	}
+}
+
+// ---------------------------- //
+// Initialize logging thresholds and the log output
+func (s *Settings) InitLog() {
+	s.Log.Level = s.LogLevel
+	s.Log.Path = s.LogPath
+	if err := logging.Init(s.Log); err != nil {
+		fmt.Printf("[%s] %v\n", s.LogPrefix, err)
+	}
+}
@@ -0,0 +1,59 @@
+package command
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+)
+
+// ---------------------------- //
+// Create the init subcommand of servers
+func newInitCommand(s *Settings, contactFile string) *cobra.Command {
+	var outputFile string
+	cmd := &cobra.Command{
+		Use:   "init",
+		Args:  cobra.NoArgs,
+		Short: "Initialize the cMix state of the server",
+		Long:  `This command initializes a new cMix client, stores the state information, and outputs the contact information to a file`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmix.InitializeServer(s.CmixConfig(), outputFile)
+		},
+	}
+	cmd.Flags().StringVarP(&outputFile, "output", "o", contactFile, "Path to output contact file")
+
+	// The certificate used to be set with -c in this command only
+	cmd.Flags().StringVarP(&s.Cert, "initCert", "c", DefaultCert, "Path to certificate file used to verify NDF download")
+	cmd.Flags().MarkHidden("initCert")
+	cmd.Flags().MarkShorthandDeprecated("initCert", "use -r or --cert instead")
+	return cmd
+}
+
+// ---------------------------- //
+// Create the export subcommand of servers
+func newExportCommand(s *Settings, contactFile string) *cobra.Command {
+	var outputFile string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Args:  cobra.NoArgs,
+		Short: "Export the contact information of the server",
+		Long:  `This command loads the existing cMix state, without modifying it, and outputs the contact information to a file`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmix.ExportServer(s.CmixConfig(), outputFile)
+		},
+	}
+	cmd.Flags().StringVarP(&outputFile, "output", "o", contactFile, "Path to output contact file")
+	return cmd
+}
+
+// ---------------------------- //
+// Create the reset subcommand of clients
+func newResetCommand(s *Settings) *cobra.Command {
+	return &cobra.Command{
+		Use:   "reset",
+		Args:  cobra.NoArgs,
+		Short: "Reset the client identity",
+		Long:  `This command wipes the cMix client state, including the persistent identity, and initializes a new state with a new identity`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmix.ResetClient(s.CmixConfig())
+		},
+	}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// ---------------------------- //
+// Config file and environment variable support for commands
+//
+// Settings are taken, from highest to lowest priority, from
+// command line flags, environment variables, the config file
+// and flag defaults
+// Each flag maps to the config file key of the same name,
+// and to the environment variable PREFIX_FLAGNAME, where
+// the flag name is in upper case (e.g. RELAY_STATEPATH)
+
+// Name of the flag with the path to the config file
+const FileFlag = "config"
+
+// Annotation marking flags that hold secrets
+const secretAnnotation = "secret"
+
+// Shown instead of the value of secrets
+const masked = "********"
+
+// ---------------------------- //
+// Add the config file flag to the command
+// The flag is persistent, so it applies to all subcommands
+func AddFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringP(FileFlag, "", "", "Path to config file (YAML, TOML or JSON)")
+}
+
+// ---------------------------- //
+// Mark a flag as holding a secret,
+// so that its value is masked when printed
+func MarkSecret(flags *pflag.FlagSet, name string) {
+	flags.SetAnnotation(name, secretAnnotation, []string{"true"})
+}
+
+func isSecret(f *pflag.Flag) bool {
+	_, ok := f.Annotations[secretAnnotation]
+	return ok
+}
+
+// ---------------------------- //
+// Return a function that loads the configuration of a command,
+// to be used as the PersistentPreRunE of the root command
+// The state password is then read from a file or prompt if not set
+func PreRun(envPrefix string) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := Load(cmd, envPrefix); err != nil {
+			return err
+		}
+		return ResolvePassword(cmd.Flags())
+	}
+}
+
+// ---------------------------- //
+// Read the config file and environment variables
+// and set the flags of the command that weren't
+// set on the command line
+func Load(cmd *cobra.Command, envPrefix string) error {
+	// Include persistent flags of the command itself
+	flags := cmd.Flags()
+	flags.AddFlagSet(cmd.PersistentFlags())
+
+	settings := make(map[string]interface{})
+	if f := flags.Lookup(FileFlag); f != nil && f.Value.String() != "" {
+		var err error
+		settings, err = readFile(f.Value.String())
+		if err != nil {
+			return fmt.Errorf("failed to read config file %s: %v", f.Value.String(), err)
+		}
+	}
+
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == FileFlag || f.Name == "help" {
+			return
+		}
+		// Environment variables take priority over the config file
+		var value interface{}
+		if env, ok := os.LookupEnv(envName(envPrefix, f.Name)); ok {
+			value = env
+		} else if setting, ok := settings[strings.ToLower(f.Name)]; ok {
+			value = setting
+		} else {
+			return
+		}
+		if setErr := setFlag(f, value); setErr != nil {
+			err = fmt.Errorf("invalid value for %s: %v", f.Name, setErr)
+			return
+		}
+		// Mark as set, so that required flags are satisfied
+		f.Changed = true
+	})
+	return err
+}
+
+// Name of the environment variable for a flag
+func envName(envPrefix, name string) string {
+	return strings.ToUpper(envPrefix + "_" + name)
+}
+
+// Read a config file in the format given by its extension
+// Top level keys are lower cased, so that they match
+// flag names regardless of case
+func readFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &settings)
+	case ".toml":
+		err = toml.Unmarshal(data, &settings)
+	case ".json":
+		err = json.Unmarshal(data, &settings)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %s", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+	lower := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		lower[strings.ToLower(k)] = v
+	}
+	return lower, nil
+}
+
+// Set a flag from a value of the config file or environment
+func setFlag(f *pflag.Flag, value interface{}) error {
+	switch value := value.(type) {
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = fmt.Sprint(item)
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			return slice.Replace(items)
+		}
+		return f.Value.Set(strings.Join(items, ","))
+	case map[string]interface{}:
+		pairs := make([]string, 0, len(value))
+		for k, v := range value {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+		}
+		sort.Strings(pairs)
+		return f.Value.Set(strings.Join(pairs, ","))
+	default:
+		return f.Value.Set(fmt.Sprint(value))
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// Names of the state password flags
+const (
+	PasswordFlag     = "statePassword"
+	PasswordFileFlag = "statePasswordFile"
+)
+
+// ---------------------------- //
+// Add the flag to read the state password from a file
+// and mark the state password flag as secret
+// Must be called after the state password flag is added
+func AddPasswordFlags(flags *pflag.FlagSet) {
+	flags.StringP(PasswordFileFlag, "", "", "Path to file containing the password for cMix state")
+	MarkSecret(flags, PasswordFlag)
+}
+
+// ---------------------------- //
+// Set the state password if it wasn't given as a flag,
+// in the config file or as an environment variable
+// The password is read from the password file if set,
+// or otherwise from an interactive prompt if running in a terminal
+// Does nothing for commands without a state password flag
+func ResolvePassword(flags *pflag.FlagSet) error {
+	password := flags.Lookup(PasswordFlag)
+	if password == nil || password.Value.String() != "" {
+		return nil
+	}
+
+	// Read from file
+	if file := flags.Lookup(PasswordFileFlag); file != nil && file.Value.String() != "" {
+		data, err := os.ReadFile(file.Value.String())
+		if err != nil {
+			return fmt.Errorf("failed to read password file: %v", err)
+		}
+		return flags.Set(PasswordFlag, strings.TrimRight(string(data), "\r\n"))
+	}
+
+	// Prompt if running interactively
+	if !isTerminal(os.Stdin) {
+		return nil
+	}
+	fmt.Fprint(os.Stderr, "Password for cMix state: ")
+	value, err := readPassword(os.Stdin)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("failed to read password: %v", err)
+	}
+	if value == "" {
+		return errors.New("password for cMix state cannot be empty")
+	}
+	return flags.Set(PasswordFlag, value)
+}
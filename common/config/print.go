@@ -0,0 +1,154 @@
+package config
+
+import (
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// ---------------------------- //
+// Create the print-config subcommand
+// It prints the effective configuration of the root command,
+// from the config file, environment variables and flag defaults,
+// in YAML format, with secrets masked
+func NewPrintCommand(envPrefix string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "print-config",
+		Args:  cobra.NoArgs,
+		Short: "Print the effective configuration",
+		Long:  `This command prints the effective configuration from the config file, environment variables and defaults, with secrets masked`,
+		// Don't resolve or require the state password,
+		// since it is masked anyway
+		PersistentPreRunE: SkipRequired,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			if err := Load(root, envPrefix); err != nil {
+				return err
+			}
+			return Print(cmd.OutOrStdout(), root.Flags())
+		},
+	}
+}
+
+// ---------------------------- //
+// PersistentPreRunE for subcommands that don't use the settings
+// of the root command, such as the state password
+// It skips the config file and password prompt, and removes
+// the required annotations of inherited flags
+func SkipRequired(cmd *cobra.Command, args []string) error {
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		delete(f.Annotations, cobra.BashCompOneRequiredFlag)
+	})
+	return nil
+}
+
+// ---------------------------- //
+// Print the values of the flags in YAML format, with secrets masked
+func Print(w io.Writer, flags *pflag.FlagSet) error {
+	settings := make(map[string]interface{})
+	names := make([]string, 0)
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Name == FileFlag || f.Name == "help" {
+			return
+		}
+		names = append(names, f.Name)
+		settings[f.Name] = flagValue(f)
+	})
+	sort.Strings(names)
+
+	// Encode one key at a time to keep flags sorted by name
+	for _, name := range names {
+		data, err := yaml.Marshal(map[string]interface{}{name: settings[name]})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get the value of a flag with its type, for encoding
+func flagValue(f *pflag.Flag) interface{} {
+	value := f.Value.String()
+	if isSecret(f) {
+		if value == "" {
+			return ""
+		}
+		return masked
+	}
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		return slice.GetSlice()
+	}
+	switch f.Value.Type() {
+	case "bool":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case "int", "int8", "int16", "int32", "int64":
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		if u, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return u
+		}
+	case "float32", "float64":
+		if x, err := strconv.ParseFloat(value, 64); err == nil {
+			return x
+		}
+	case "stringToString", "stringToInt", "stringToInt64":
+		return mapValue(value)
+	}
+	return value
+}
+
+// Parse the string representation of a map flag, [k1=v1,k2=v2]
+func mapValue(value string) map[string]string {
+	m := make(map[string]string)
+	if len(value) < 2 {
+		return m
+	}
+	value = value[1 : len(value)-1]
+	if value == "" {
+		return m
+	}
+	for _, pair := range splitPairs(value) {
+		for i := 0; i < len(pair); i++ {
+			if pair[i] == '=' {
+				m[pair[:i]] = pair[i+1:]
+				break
+			}
+		}
+	}
+	return m
+}
+
+// Split comma separated pairs, allowing quoted values
+func splitPairs(value string) []string {
+	pairs := make([]string, 0)
+	start, quoted := 0, false
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				pairs = append(pairs, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	pairs = append(pairs, value[start:])
+	for i, pair := range pairs {
+		if unquoted, err := strconv.Unquote(pair); err == nil {
+			pairs[i] = unquoted
+		}
+	}
+	return pairs
+}
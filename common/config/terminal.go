@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// Check if the file is a terminal
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// Read a line from the terminal without echoing it
+// If the prompt is interrupted, the terminal state
+// is restored before exiting, so that echo isn't left off
+func readPassword(f *os.File) (string, error) {
+	fd := int(f.Fd())
+	state, err := term.GetState(fd)
+	if err != nil {
+		return "", err
+	}
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer func() {
+		signal.Stop(signals)
+		close(done)
+	}()
+	go func() {
+		select {
+		case <-signals:
+			term.Restore(fd, state)
+			fmt.Fprintln(os.Stderr)
+			os.Exit(1)
+		case <-done:
+		}
+	}()
+	password, err := term.ReadPassword(fd)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
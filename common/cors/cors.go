@@ -0,0 +1,174 @@
+package cors
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	rscors "github.com/rs/cors"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/pflag"
+)
+
+// ---------------------------- //
+// Browser origin policy of the local HTTP proxies
+//
+// Websites open in the browser can send requests to local servers,
+// so requests with an Origin header are only accepted from allowed
+// origins, and CORS headers are only sent to them
+// Requests without an Origin header don't come from websites,
+// and are not restricted
+// Requests are counted by origin, and logged each time the count
+// reaches a power of ten, to spot pages that abuse the proxy
+
+// Origins allowed by default: browser extensions such as
+// MetaMask, and websites served from the local machine
+var DefaultAllowedOrigins = []string{
+	"chrome-extension://*",
+	"moz-extension://*",
+	"http://localhost",
+	"http://localhost:*",
+	"http://127.0.0.1",
+	"http://127.0.0.1:*",
+}
+
+// Methods and headers allowed by default in cross-origin requests
+var (
+	DefaultAllowedMethods = []string{http.MethodGet, http.MethodPost}
+	DefaultAllowedHeaders = []string{"Accept", "Authorization", "Content-Type", "X-Requested-With"}
+)
+
+// Default time browsers cache preflight responses
+const DefaultMaxAge = 10 * time.Minute
+
+// ---------------------------- //
+// Config of the browser origin policy
+type Config struct {
+	// Origins allowed to send requests, such as https://app.example.com,
+	// with * as wildcard (e.g. chrome-extension://*)
+	// A single * allows any origin
+	AllowedOrigins []string
+
+	// Methods and headers allowed in cross-origin requests
+	AllowedMethods []string
+	AllowedHeaders []string
+
+	// Time browsers cache preflight responses
+	MaxAge time.Duration
+}
+
+// ---------------------------- //
+// Register the flags of the browser origin policy
+func AddFlags(flags *pflag.FlagSet, c *Config) {
+	flags.StringSliceVarP(&c.AllowedOrigins, "allowedOrigins", "", DefaultAllowedOrigins, "Origins of websites allowed to send requests, with * as wildcard (* = any website)")
+	flags.StringSliceVarP(&c.AllowedMethods, "allowedMethods", "", DefaultAllowedMethods, "HTTP methods allowed in requests from websites")
+	flags.StringSliceVarP(&c.AllowedHeaders, "allowedHeaders", "", DefaultAllowedHeaders, "HTTP headers allowed in requests from websites (* = any header)")
+	flags.DurationVarP(&c.MaxAge, "corsMaxAge", "", DefaultMaxAge, "Time browsers cache the allowed methods and headers of a website")
+}
+
+// Maximum number of origins with their own request count
+// Requests from other origins are counted together, since
+// any client can send requests with many different origins
+const maxCountedOrigins = 1000
+
+// ---------------------------- //
+// Policy applies a Config to HTTP requests
+type Policy struct {
+	cors      *rscors.Cors
+	logPrefix string
+
+	mux    sync.Mutex
+	counts map[string]uint64
+	// Requests from origins beyond maxCountedOrigins
+	otherAllowed  uint64
+	otherRejected uint64
+}
+
+// Create a policy from the config
+// Logs use the given prefix
+func New(c Config, logPrefix string) *Policy {
+	// An empty list would allow any origin
+	origins := c.AllowedOrigins
+	if len(origins) == 0 {
+		origins = []string{""}
+	}
+	return &Policy{
+		cors: rscors.New(rscors.Options{
+			AllowedOrigins: origins,
+			AllowedMethods: c.AllowedMethods,
+			AllowedHeaders: c.AllowedHeaders,
+			MaxAge:         int(c.MaxAge.Seconds()),
+		}),
+		logPrefix: logPrefix,
+		counts:    make(map[string]uint64),
+	}
+}
+
+// ---------------------------- //
+// Wrap a handler, rejecting requests from origins not allowed,
+// answering preflight requests and adding CORS headers
+func (p *Policy) Handler(h http.Handler) http.Handler {
+	corsHandler := p.cors.Handler(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		allowed := p.cors.OriginAllowed(r)
+		p.count(origin, allowed)
+		if !allowed {
+			// 403 Forbidden
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		corsHandler.ServeHTTP(w, r)
+	})
+}
+
+// Count a request from an origin, logging
+// when the count reaches a power of ten
+// Once maxCountedOrigins origins are counted, requests from
+// new origins are counted together by whether they are allowed
+func (p *Policy) count(origin string, allowed bool) {
+	p.mux.Lock()
+	_, counted := p.counts[origin]
+	if !counted && len(p.counts) >= maxCountedOrigins {
+		var n uint64
+		if allowed {
+			p.otherAllowed++
+			n = p.otherAllowed
+		} else {
+			p.otherRejected++
+			n = p.otherRejected
+		}
+		p.mux.Unlock()
+		if !isPowerOfTen(n) {
+			return
+		}
+		if allowed {
+			jww.INFO.Printf("[%s] Origins not counted individually sent %d requests", p.logPrefix, n)
+		} else {
+			jww.WARN.Printf("[%s] Origins not counted individually are not allowed, %d requests rejected", p.logPrefix, n)
+		}
+		return
+	}
+	p.counts[origin]++
+	n := p.counts[origin]
+	p.mux.Unlock()
+	if !isPowerOfTen(n) {
+		return
+	}
+	if allowed {
+		jww.INFO.Printf("[%s] Origin %s sent %d requests", p.logPrefix, origin, n)
+	} else {
+		jww.WARN.Printf("[%s] Origin %s is not allowed, %d requests rejected", p.logPrefix, origin, n)
+	}
+}
+
+func isPowerOfTen(n uint64) bool {
+	for n >= 10 && n%10 == 0 {
+		n /= 10
+	}
+	return n == 1
+}
@@ -0,0 +1,62 @@
+module github.com/xx-labs/blockchain-cmix-relay/common
+
+go 1.20
+
+require (
+	github.com/pelletier/go-toml/v2 v2.0.2
+	github.com/rs/cors v1.8.2
+	github.com/spf13/cobra v1.7.0
+	github.com/spf13/jwalterweatherman v1.1.0
+	github.com/spf13/pflag v1.0.5
+	github.com/xx-labs/blockchain-cmix-relay/cmix v0.0.0-00010101000000-000000000000
+	golang.org/x/term v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	filippo.io/edwards25519 v1.0.0 // indirect
+	git.xx.network/elixxir/grpc-web-go-client v0.0.0-20230214175953-5b5a8c33d28a // indirect
+	github.com/badoux/checkmail v1.2.1 // indirect
+	github.com/cenkalti/backoff/v4 v4.1.3 // indirect
+	github.com/cloudflare/circl v1.2.0 // indirect
+	github.com/desertbit/timer v0.0.0-20180107155436-c41aec40b27f // indirect
+	github.com/elliotchance/orderedmap v1.4.0 // indirect
+	github.com/golang-collections/collections v0.0.0-20130729185459-604e922904d3 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/improbable-eng/grpc-web v0.15.0 // indirect
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/klauspost/cpuid/v2 v2.1.0 // indirect
+	github.com/mitchellh/go-homedir v1.1.0 // indirect
+	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e // indirect
+	github.com/soheilhy/cmux v0.1.5 // indirect
+	github.com/ttacon/builder v0.0.0-20170518171403-c099f663e1c2 // indirect
+	github.com/ttacon/libphonenumber v1.2.1 // indirect
+	github.com/tyler-smith/go-bip39 v1.1.0 // indirect
+	github.com/zeebo/blake3 v0.2.3 // indirect
+	gitlab.com/elixxir/bloomfilter v0.0.0-20230315224936-a4459418f300 // indirect
+	gitlab.com/elixxir/client/v4 v4.6.3 // indirect
+	gitlab.com/elixxir/comms v0.0.4-0.20230310205528-f06faa0d2f0b // indirect
+	gitlab.com/elixxir/crypto v0.0.7-0.20230413162806-a99ec4bfea32 // indirect
+	gitlab.com/elixxir/ekv v0.2.1 // indirect
+	gitlab.com/elixxir/primitives v0.0.3-0.20230214180039-9a25e2d3969c // indirect
+	gitlab.com/xx_network/comms v0.0.4-0.20230214180029-5387fb85736d // indirect
+	gitlab.com/xx_network/crypto v0.0.5-0.20230214003943-8a09396e95dd // indirect
+	gitlab.com/xx_network/primitives v0.0.4-0.20230310205521-c440e68e34c4 // indirect
+	gitlab.com/xx_network/ring v0.0.3-0.20220902183151-a7d3b15bc981 // indirect
+	golang.org/x/crypto v0.5.0 // indirect
+	golang.org/x/net v0.7.0 // indirect
+	golang.org/x/sys v0.6.0 // indirect
+	golang.org/x/text v0.7.0 // indirect
+	google.golang.org/genproto v0.0.0-20220822174746-9e6da59bd2fc // indirect
+	google.golang.org/grpc v1.49.0 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+	nhooyr.io/websocket v1.8.7 // indirect
+	src.agwa.name/tlshacks v0.0.0-20220518131152-d2c6f4e2b780 // indirect
+)
+
+replace (
+	github.com/xx-labs/blockchain-cmix-relay/cmix => ../cmix
+)
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// ---------------------------- //
+// Register the flags of the shared logging options
+// The level and path are registered by each command,
+// since their defaults differ between commands
+func AddFlags(flags *pflag.FlagSet, c *Config) {
+	if c.Format == "" {
+		c.Format = FormatText
+	}
+	flags.VarP(&c.Format, "logFormat", "", "Format of log lines (text, json, logfmt)")
+	flags.BoolVarP(&c.Stderr, "logStderr", "", false, "Write logs to stderr instead of the log file")
+	flags.Int64VarP(&c.RotateSize, "logRotateSize", "", 0, "Rotate the log file when it exceeds this size in megabytes (0 = no size based rotation)")
+	flags.DurationVarP(&c.RotateInterval, "logRotateInterval", "", 0, "Rotate the log file after this interval (0 = no time based rotation)")
+	flags.IntVarP(&c.MaxBackups, "logMaxBackups", "", 0, "Maximum number of rotated log files kept (0 = keep all)")
+	flags.DurationVarP(&c.MaxBackupAge, "logMaxBackupAge", "", 0, "Maximum age of rotated log files kept (0 = keep all)")
+}
+
+// ---------------------------- //
+// Format implements pflag.Value
+
+func (f *Format) String() string {
+	return string(*f)
+}
+
+func (f *Format) Set(value string) error {
+	format, err := ParseFormat(value)
+	if err != nil {
+		return err
+	}
+	*f = format
+	return nil
+}
+
+func (f *Format) Type() string {
+	return "string"
+}
@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formatWriter re-encodes jww log lines in a structured format
+// jww lines have the form
+//
+//	LEVEL [prefix key=value] message
+//
+// The prefix is optional, and key=value pairs in it become fields
+type formatWriter struct {
+	format Format
+	out    io.Writer
+}
+
+// A parsed log line
+type entry struct {
+	time    time.Time
+	level   string
+	prefix  string
+	fields  [][2]string
+	message string
+}
+
+func (f *formatWriter) Write(p []byte) (int, error) {
+	e := parseLine(strings.TrimRight(string(p), "\n"))
+	var line []byte
+	if f.format == FormatJson {
+		line = e.json()
+	} else {
+		line = e.logfmt()
+	}
+	if _, err := f.out.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Parse a jww log line
+func parseLine(line string) entry {
+	e := entry{time: time.Now().UTC()}
+	e.level, line, _ = strings.Cut(line, " ")
+	if strings.HasPrefix(line, "[") {
+		if end := strings.Index(line, "] "); end > 0 {
+			e.parsePrefix(line[1:end])
+			line = line[end+2:]
+		}
+	}
+	e.message = line
+	return e
+}
+
+// Split the prefix into plain words and key=value fields
+func (e *entry) parsePrefix(prefix string) {
+	words := make([]string, 0)
+	for _, word := range strings.Fields(prefix) {
+		if key, value, ok := strings.Cut(word, "="); ok && key != "" {
+			e.fields = append(e.fields, [2]string{key, value})
+		} else {
+			words = append(words, word)
+		}
+	}
+	e.prefix = strings.Join(words, " ")
+}
+
+// Encode the entry as a JSON object
+// Keys are written in a fixed order
+func (e entry) json() []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	writeJsonField(&buf, "time", e.time.Format(time.RFC3339Nano), true)
+	writeJsonField(&buf, "level", e.level, false)
+	if e.prefix != "" {
+		writeJsonField(&buf, "prefix", e.prefix, false)
+	}
+	for _, field := range e.fields {
+		writeJsonField(&buf, field[0], field[1], false)
+	}
+	writeJsonField(&buf, "msg", e.message, false)
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+func writeJsonField(buf *bytes.Buffer, key, value string, first bool) {
+	if !first {
+		buf.WriteByte(',')
+	}
+	k, _ := json.Marshal(key)
+	v, _ := json.Marshal(value)
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+}
+
+// Encode the entry as logfmt key=value pairs
+func (e entry) logfmt() []byte {
+	var buf bytes.Buffer
+	writeLogfmtField(&buf, "time", e.time.Format(time.RFC3339Nano), true)
+	writeLogfmtField(&buf, "level", e.level, false)
+	if e.prefix != "" {
+		writeLogfmtField(&buf, "prefix", e.prefix, false)
+	}
+	for _, field := range e.fields {
+		writeLogfmtField(&buf, field[0], field[1], false)
+	}
+	writeLogfmtField(&buf, "msg", e.message, false)
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func writeLogfmtField(buf *bytes.Buffer, key, value string, first bool) {
+	if !first {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	// Quote values that would break parsing
+	if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+		buf.WriteString(strconv.Quote(value))
+	} else {
+		buf.WriteString(value)
+	}
+}
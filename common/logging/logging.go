@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// ---------------------------- //
+// Config of the logging setup shared by all commands
+type Config struct {
+	// Level of logs to write (0 = info, 1 = debug, >1 = trace)
+	Level uint
+
+	// Path to the log file
+	// Ignored if Stderr is set
+	Path string
+
+	// Write logs to stderr instead of a file, for containers
+	Stderr bool
+
+	// Format of log lines
+	Format Format
+
+	// Rotate the log file when it exceeds this size in megabytes
+	// Size based rotation is disabled if this is zero
+	RotateSize int64
+
+	// Rotate the log file after this interval
+	// Time based rotation is disabled if this is zero
+	RotateInterval time.Duration
+
+	// Maximum number of rotated log files kept
+	// All rotated files are kept if this is zero
+	MaxBackups int
+
+	// Maximum age of rotated log files kept
+	// Rotated files are kept regardless of age if this is zero
+	MaxBackupAge time.Duration
+}
+
+// Format of log lines
+type Format string
+
+const (
+	// Free-form text lines, as written by jww
+	FormatText Format = "text"
+	// One JSON object per line
+	FormatJson Format = "json"
+	// Space separated key=value pairs
+	FormatLogfmt Format = "logfmt"
+)
+
+// ---------------------------- //
+// Parse a log format
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case FormatText, FormatJson, FormatLogfmt:
+		return Format(format), nil
+	case "":
+		return FormatText, nil
+	}
+	return FormatText, fmt.Errorf("invalid log format %s", format)
+}
+
+// ---------------------------- //
+// Initialize jww logging thresholds and output
+// The log file is appended to, and rotated if configured
+// Returns an error if the log file can't be opened,
+// in which case logs are written to stderr
+func Init(c Config) error {
+	// Check the level of logs to display
+	if c.Level > 1 {
+		// Turn on trace logs
+		jww.SetLogThreshold(jww.LevelTrace)
+	} else if c.Level == 1 {
+		// Turn on debugging logs
+		jww.SetLogThreshold(jww.LevelDebug)
+	} else {
+		// Turn on info logs
+		jww.SetLogThreshold(jww.LevelInfo)
+	}
+
+	// Open output
+	var out io.Writer = os.Stderr
+	var err error
+	if !c.Stderr {
+		out, err = openRotatingFile(c)
+		if err != nil {
+			out = os.Stderr
+			err = fmt.Errorf("could not open log file %s: %v", c.Path, err)
+		}
+	}
+
+	// Structured formats add their own timestamp
+	if c.Format == FormatJson || c.Format == FormatLogfmt {
+		jww.SetFlags(0)
+		out = &formatWriter{format: c.Format, out: out}
+	} else {
+		jww.SetFlags(log.Ldate | log.Ltime)
+	}
+	jww.SetLogOutput(out)
+	jww.SetStdoutOutput(io.Discard)
+	return err
+}
+
+// ---------------------------- //
+// Generate a random id to correlate the log lines of a request
+// Request ids are only used in logs and never sent over cMix
+func NewRequestId() string {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+// ---------------------------- //
+// Add a request id to a log prefix
+// Structured formats write it as the request_id field
+func WithRequestId(prefix, id string) string {
+	return prefix + " " + requestIdKey + "=" + id
+}
+
+const requestIdKey = "request_id"
@@ -0,0 +1,191 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const megabyte = 1024 * 1024
+
+// Time format of the suffix of rotated log files
+const rotateSuffixFormat = "20060102-150405"
+
+// Interval between attempts to open the log file again,
+// while logging to standard error
+const reopenInterval = time.Minute
+
+// rotatingFile is a log file that is rotated
+// when it exceeds a size or age
+// Rotated files are renamed with a timestamp suffix
+// and removed according to the retention settings
+type rotatingFile struct {
+	path           string
+	rotateSize     int64
+	rotateInterval time.Duration
+	maxBackups     int
+	maxBackupAge   time.Duration
+
+	mux    sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	// Set if the last rotation failed, so that
+	// the failure is only reported once
+	rotateFailed bool
+
+	// Set while logging to standard error, since the log
+	// file couldn't be opened again after a rotation
+	fallback bool
+	retried  time.Time
+}
+
+// Open the log file for appending
+func openRotatingFile(c Config) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:           c.Path,
+		rotateSize:     c.RotateSize * megabyte,
+		rotateInterval: c.RotateInterval,
+		maxBackups:     c.MaxBackups,
+		maxBackupAge:   c.MaxBackupAge,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.fallback {
+		r.reopen()
+	} else if r.shouldRotate(len(p)) {
+		err := r.rotate()
+		if err != nil && !r.rotateFailed {
+			fmt.Fprintf(os.Stderr, "Could not rotate log file %s: %v\n", r.path, err)
+		}
+		r.rotateFailed = err != nil
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) shouldRotate(size int) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.rotateSize > 0 && r.size+int64(size) > r.rotateSize {
+		return true
+	}
+	return r.rotateInterval > 0 && time.Since(r.opened) >= r.rotateInterval
+}
+
+// Open the log file and get its current size
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	r.opened = time.Now()
+	return nil
+}
+
+// Rename the current log file and open a new one
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	backup := r.path + "." + time.Now().Format(rotateSuffixFormat)
+	if _, err := os.Stat(backup); err == nil {
+		backup = fmt.Sprintf("%s-%d", backup, time.Now().UnixNano())
+	}
+	if err := os.Rename(r.path, backup); err != nil {
+		// Keep writing to the current file
+		if openErr := r.open(); openErr != nil {
+			r.useStderr(openErr)
+		}
+		return err
+	}
+	if err := r.open(); err != nil {
+		// Restore the current file, or log to standard error
+		if os.Rename(backup, r.path) != nil || r.open() != nil {
+			r.useStderr(err)
+		}
+		return err
+	}
+	r.removeBackups()
+	return nil
+}
+
+// Log to standard error until the log file can be opened again
+func (r *rotatingFile) useStderr(err error) {
+	fmt.Fprintf(os.Stderr, "Could not open log file %s, logging to standard error: %v\n", r.path, err)
+	r.file = os.Stderr
+	r.size = 0
+	r.fallback = true
+	r.retried = time.Now()
+}
+
+// Try to open the log file again, at most once per reopenInterval
+func (r *rotatingFile) reopen() {
+	if time.Since(r.retried) < reopenInterval {
+		return
+	}
+	r.retried = time.Now()
+	if err := r.open(); err == nil {
+		r.fallback = false
+		fmt.Fprintf(os.Stderr, "Log file %s opened again\n", r.path)
+	}
+}
+
+// Remove rotated files exceeding the retention settings
+func (r *rotatingFile) removeBackups() {
+	if r.maxBackups <= 0 && r.maxBackupAge <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return
+	}
+	backups := make([]string, 0, len(matches))
+	for _, match := range matches {
+		suffix := strings.TrimPrefix(match, r.path+".")
+		if _, err := time.Parse(rotateSuffixFormat, suffix[:min(len(suffix), len(rotateSuffixFormat))]); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	// Newest first
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	for i, backup := range backups {
+		remove := r.maxBackups > 0 && i >= r.maxBackups
+		if !remove && r.maxBackupAge > 0 {
+			if info, err := os.Stat(backup); err == nil && time.Since(info.ModTime()) > r.maxBackupAge {
+				remove = true
+			}
+		}
+		if remove {
+			os.Remove(backup)
+		}
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
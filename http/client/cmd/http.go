@@ -9,9 +9,9 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/rs/cors"
 	jww "github.com/spf13/jwalterweatherman"
 	"github.com/xx-labs/blockchain-cmix-relay/cmix"
+	"github.com/xx-labs/blockchain-cmix-relay/common/cors"
 	"gitlab.com/elixxir/client/v4/restlike"
 	"gitlab.com/elixxir/crypto/contact"
 )
@@ -24,16 +24,26 @@ type HttpProxy struct {
 	srv       *http.Server
 }
 
-func NewHttpProxy(c *cmix.Client, port int, contactFile, logPrefix string) *HttpProxy {
+// Create a new HTTP proxy server
+// The browser origin policy applies to requests sent to
+// the proxy itself, such as http://localhost:9296/path
+// Requests proxied for the browser, with the full URL, are
+// not restricted, since CORS is up to the target website
+func NewHttpProxy(c *cmix.Client, port int, contactFile, logPrefix string, origins *cors.Policy) *HttpProxy {
 	contact := cmix.LoadContactFile(contactFile)
 	hp := &HttpProxy{c, port, contact, logPrefix, nil}
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", hp.ServeHTTP)
-	// Create a new CORS handler with desired options
-	corsHandler := cors.AllowAll().Handler(mux)
+	originsHandler := origins.Handler(mux)
 	hp.srv = &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: corsHandler,
+		Addr: fmt.Sprintf(":%d", port),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.RequestURI[0] == '/' {
+				originsHandler.ServeHTTP(w, r)
+			} else {
+				mux.ServeHTTP(w, r)
+			}
+		}),
 	}
 	return hp
 }
@@ -55,8 +65,10 @@ func (hp *HttpProxy) Stop() {
 	defer func() {
 		cancel()
 	}()
+	// Wait for requests in progress, then close remaining connections
 	if err := hp.srv.Shutdown(ctx); err != nil {
-		jww.FATAL.Panicf("[%s] Error stopping HTTP server: %v", hp.logPrefix, err)
+		jww.ERROR.Printf("[%s] Error stopping HTTP server, closing connections: %v", hp.logPrefix, err)
+		hp.srv.Close()
 	}
 	jww.INFO.Printf("[%s] HTTP stopped", hp.logPrefix)
 }
@@ -3,10 +3,10 @@ module github.com/xx-labs/blockchain-cmix-relay/http/client
 go 1.20
 
 require (
-	github.com/rs/cors v1.8.2
 	github.com/spf13/cobra v1.7.0
 	github.com/spf13/jwalterweatherman v1.1.0
-	github.com/xx-labs/blockchain-cmix-relay/cmix v0.0.0-20230607223537-9b6b35823669
+	github.com/xx-labs/blockchain-cmix-relay/cmix v0.0.0-00010101000000-000000000000
+	github.com/xx-labs/blockchain-cmix-relay/common v0.0.0-00010101000000-000000000000
 	gitlab.com/elixxir/client/v4 v4.6.3
 	gitlab.com/elixxir/crypto v0.0.7-0.20230413162806-a99ec4bfea32
 )
@@ -28,7 +28,9 @@ require (
 	github.com/klauspost/cpuid/v2 v2.1.0 // indirect
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646 // indirect
+	github.com/pelletier/go-toml/v2 v2.0.2 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
+	github.com/rs/cors v1.8.2 // indirect
 	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e // indirect
 	github.com/soheilhy/cmux v0.1.5 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
@@ -46,12 +48,19 @@ require (
 	gitlab.com/xx_network/ring v0.0.3-0.20220902183151-a7d3b15bc981 // indirect
 	go.uber.org/atomic v1.10.0 // indirect
 	golang.org/x/crypto v0.5.0 // indirect
-	golang.org/x/net v0.5.0 // indirect
-	golang.org/x/sys v0.4.0 // indirect
-	golang.org/x/text v0.6.0 // indirect
+	golang.org/x/net v0.7.0 // indirect
+	golang.org/x/sys v0.6.0 // indirect
+	golang.org/x/term v0.5.0 // indirect
+	golang.org/x/text v0.7.0 // indirect
 	google.golang.org/genproto v0.0.0-20220822174746-9e6da59bd2fc // indirect
 	google.golang.org/grpc v1.49.0 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 	nhooyr.io/websocket v1.8.7 // indirect
 	src.agwa.name/tlshacks v0.0.0-20220518131152-d2c6f4e2b780 // indirect
 )
+
+replace (
+	github.com/xx-labs/blockchain-cmix-relay/cmix => ../../cmix
+	github.com/xx-labs/blockchain-cmix-relay/common => ../../common
+)
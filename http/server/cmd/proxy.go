@@ -25,7 +25,7 @@ type Header struct {
 // to process a restlike request
 // This function proxies an HTTP request received over cMix
 func (h *HttpProxy) Callback(request *restlike.Message) *restlike.Message {
-	jww.INFO.Printf("[%s] Request received over cMix: %v", logPrefix, request)
+	jww.INFO.Printf("[%s] Request received over cMix: %v", settings.LogPrefix, request)
 
 	// Response
 	respHeaders := make([]Header, 0)
@@ -40,7 +40,7 @@ func (h *HttpProxy) Callback(request *restlike.Message) *restlike.Message {
 	var headers []Header
 	err := json.Unmarshal(request.Headers.Headers, &headers)
 	if err != nil {
-		jww.ERROR.Printf("[%s] Error parsing request headers: %v", logPrefix, err)
+		jww.ERROR.Printf("[%s] Error parsing request headers: %v", settings.LogPrefix, err)
 	} else {
 		// Convert headers to HTTP headers
 		httpHeaders := make(http.Header, len(headers))
@@ -59,16 +59,16 @@ func (h *HttpProxy) Callback(request *restlike.Message) *restlike.Message {
 		method := httpHeaders.Get("X-PROXXY-METHOD")
 
 		// Create HTTP request
-		jww.INFO.Printf("[%s] Performing %s HTTP request to %s", logPrefix, method, url)
+		jww.INFO.Printf("[%s] Performing %s HTTP request to %s", settings.LogPrefix, method, url)
 		req, err := http.NewRequest(method, url, bytes.NewBuffer(request.Content))
 		if err != nil {
-			jww.ERROR.Printf("[%s] Error creating %s HTTP request to %v: %v", logPrefix, method, url, err)
+			jww.ERROR.Printf("[%s] Error creating %s HTTP request to %v: %v", settings.LogPrefix, method, url, err)
 			code = "500"
 		} else {
 			client := &http.Client{}
 			resp, err := client.Do(req)
 			if err != nil {
-				jww.ERROR.Printf("[%s] Error performing %s HTTP request to %v: %v", logPrefix, method, url, err)
+				jww.ERROR.Printf("[%s] Error performing %s HTTP request to %v: %v", settings.LogPrefix, method, url, err)
 				code = "500"
 			} else {
 				defer resp.Body.Close()
@@ -81,7 +81,7 @@ func (h *HttpProxy) Callback(request *restlike.Message) *restlike.Message {
 				code = fmt.Sprintf("%d", resp.StatusCode)
 				// Copy body from HTTP response
 				response.Content = body
-				jww.INFO.Printf("[%s] Sending response back to client", logPrefix)
+				jww.INFO.Printf("[%s] Sending response back to client", settings.LogPrefix)
 			}
 		}
 	}
@@ -90,7 +90,7 @@ func (h *HttpProxy) Callback(request *restlike.Message) *restlike.Message {
 	// Copy headers to cmix response
 	headerData, err := json.Marshal(respHeaders)
 	if err != nil {
-		jww.ERROR.Printf("[%s] Error marshalling response headers: %v", logPrefix, err)
+		jww.ERROR.Printf("[%s] Error marshalling response headers: %v", settings.LogPrefix, err)
 		// Client will catch this as an internal server error
 	} else {
 		response.Headers.Headers = headerData